@@ -7,7 +7,14 @@ import (
 // Tree grabs a node by its parent.
 func (m MatcherFunc) Tree(a *AST) MatcherFunc {
 	return func(c *Code) bool {
-		if m(c) {
+		ini := c.hold()
+		ok := m(c)
+		c.release()
+		if ok {
+			c.ast.Span = c.span(ini, c.Mark())
+			if c.cst {
+				c.ast.trivia(c.text)
+			}
 			*a = *c.ast
 			return true
 		}
@@ -18,28 +25,36 @@ func (m MatcherFunc) Tree(a *AST) MatcherFunc {
 // Leaf builds a leaf node.
 func (m MatcherFunc) Leaf(Type string) MatcherFunc {
 	return func(c *Code) bool {
-		if ini := c.Mark(); m(c) {
-			leaf := &AST{Type: Type, Name: c.Token(ini, c.Mark())}
+		ini := c.hold()
+		ok := m(c)
+		c.release()
+		if ok {
+			end := c.Mark()
+			leaf := &AST{Type: Type, Name: c.Token(ini, end), Span: c.span(ini, end)}
 			c.ast.Args = append(c.ast.Args, leaf)
-			return true
 		}
-		return false
+		return ok
 	}
 }
 
 // Root converts three Leaf nodes into a binary branch.
 // In other words, it will set the middle node as a root
 // node and add the left and right nodes as its children.
-// Example: [ 2, +, 4 ] becomes [ + [ 2, 4 ] ].
+// Example: [ 2, +, 4 ] becomes [ + [ 2, 4 ] ]. The span
+// of the root node covers the three of them.
 func Root(left, root, right MatcherFunc) MatcherFunc {
 	m := AND(left, root, right)
 	return func(c *Code) bool {
 		parent := c.ast
-		if m(c) {
+		ini := c.hold()
+		ok := m(c)
+		c.release()
+		if ok {
 			if len(parent.Args) >= 3 {
 				args := parent.Args[len(parent.Args)-3:]
 				a, o, b := args[0], args[1], args[2]
 				o.Args = append(o.Args, a, b)
+				o.Span = c.span(ini, c.Mark())
 				parent.Args = append(parent.Args[:len(parent.Args)-3], o)
 			}
 			return true
@@ -65,8 +80,20 @@ func (m MatcherFunc) enter() MatcherFunc {
 }
 
 // Child makes nodes children of a node.
+// The span of the node covers its children.
 func (m MatcherFunc) Child(ms ...MatcherFunc) MatcherFunc {
-	return And(m.enter(), And(ms...)).leave()
+	child := And(m.enter(), And(ms...)).leave()
+	return func(c *Code) bool {
+		parent := c.ast
+		n := len(parent.Args)
+		ini := c.hold()
+		ok := child(c)
+		c.release()
+		if ok && len(parent.Args) > n {
+			parent.Right().Span = c.span(ini, c.Mark())
+		}
+		return ok
+	}
 }
 
 // leave is the opposite of Enter. Useful
@@ -87,7 +114,11 @@ func (m MatcherFunc) Group(Type string) MatcherFunc {
 		parent := c.ast
 		group := &AST{Type: Type}
 		c.ast = group
-		if m(c) {
+		ini := c.hold()
+		ok := m(c)
+		c.release()
+		if ok {
+			group.Span = c.span(ini, c.Mark())
 			parent.Args = append(parent.Args, group)
 			return true
 		}
@@ -121,6 +152,36 @@ type AST struct {
 	Type string
 	Name Token
 	Args []*AST
+	Span Span // Input consumed by the node.
+
+	Leading  string // Trivia before the node, in CST mode.
+	Trailing string // Trivia after the node, in CST mode.
+}
+
+// Span is a part of the input, from Start to End.
+type Span struct {
+	Start Mark
+	End   Mark
+}
+
+// Text returns the source text of the node,
+// given the source code it was parsed from.
+func (a *AST) Text(src string) string {
+	return src[a.Span.Start.pos:a.Span.End.pos]
+}
+
+// span returns the span between ini and end. In
+// token codes the marks are converted from token
+// indexes to byte offsets.
+func (c *Code) span(ini, end Mark) Span {
+	ini.ind, end.ind = nil, nil
+	if c.toks != nil {
+		tok := c.tokens(ini, end)
+		ini = Mark{pos: tok.Pos, row: tok.Row, col: tok.Col}
+		end = c.after(ini, tok.Text)
+		end.pos = tok.Pos + len(tok.Text)
+	}
+	return Span{Start: ini, End: end}
 }
 
 // Left returns the leftmost node.
@@ -140,7 +201,8 @@ func (a AST) String() string {
 }
 
 // Print returns a string representation
-// of the AST given a format.
+// of the AST given a format. It panics if
+// the format is unknown, like PrintTree.
 func (a *AST) Print(format string) string {
 	var buf bytes.Buffer
 	PrintTree(&buf, format, a)
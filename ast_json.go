@@ -0,0 +1,62 @@
+package calm
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// ParseAST reads an AST in the json-full or
+// json-full-inline format of PrintTree. It reads
+// the json formats too, that have no positions.
+func ParseAST(r io.Reader) (*AST, error) {
+	var a AST
+	if err := json.NewDecoder(r).Decode(&a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// jsonAST is an AST in the json-full format of PrintTree.
+type jsonAST struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	Pos  *int   `json:"pos,omitempty"`
+	Row  int    `json:"row,omitempty"`
+	Col  int    `json:"col,omitempty"`
+	Kind string `json:"kind,omitempty"`
+	Span []int  `json:"span,omitempty"`
+	Lead string `json:"leading,omitempty"`
+	Tail string `json:"trailing,omitempty"`
+	Args []*AST `json:"args,omitempty"`
+}
+
+// MarshalJSON encodes the AST like
+// the json-full format of PrintTree.
+func (a *AST) MarshalJSON() ([]byte, error) {
+	j := jsonAST{Type: a.Type, Name: a.Name.Text, Kind: a.Name.Kind, Lead: a.Leading, Tail: a.Trailing, Args: a.Args}
+	if a.Name.Row > 0 {
+		j.Pos, j.Row, j.Col = &a.Name.Pos, a.Name.Row, a.Name.Col
+	}
+	if s, e := a.Span.Start, a.Span.End; s.row > 0 {
+		j.Span = []int{s.pos, s.row, s.col, e.pos, e.row, e.col}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes an AST in
+// the json-full format of PrintTree.
+func (a *AST) UnmarshalJSON(b []byte) error {
+	var j jsonAST
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	*a = AST{Type: j.Type, Name: Token{Text: j.Name, Row: j.Row, Col: j.Col, Kind: j.Kind}, Args: j.Args, Leading: j.Lead, Trailing: j.Tail}
+	if j.Pos != nil {
+		a.Name.Pos = *j.Pos
+	}
+	if len(j.Span) == 6 {
+		s := j.Span
+		a.Span = Span{Start: Mark{pos: s[0], row: s[1], col: s[2]}, End: Mark{pos: s[3], row: s[4], col: s[5]}}
+	}
+	return nil
+}
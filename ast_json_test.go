@@ -0,0 +1,55 @@
+package calm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAST(t *testing.T) {
+
+	var ast AST
+	parseExpr(&ast)
+	ast.Args[0].Left().Name.Kind = "Int"
+
+	for _, format := range []string{"json-full", "json-full-inline"} {
+
+		got, err := ParseAST(strings.NewReader(ast.Print(format)))
+
+		assert.NoError(t, err, format)
+		assert.Equal(t, &ast, got, format)
+	}
+
+	got, err := ParseAST(strings.NewReader(ast.Print("json")))
+
+	assert.NoError(t, err)
+	assert.Equal(t, ast.Print("short"), got.Print("short"))
+}
+
+func TestParseAST_Error(t *testing.T) {
+
+	_, err := ParseAST(strings.NewReader(`{ "type": `))
+
+	assert.Error(t, err)
+}
+
+func TestAST_JSON(t *testing.T) {
+
+	ast := &AST{Type: "Root", Args: []*AST{
+		{Type: "Str", Name: Token{Text: `"a"`, Pos: 0, Row: 1, Col: 1}},
+		{Type: "Empty", Name: Token{Pos: 3, Row: 1, Col: 4}},
+	}}
+
+	b, err := json.Marshal(ast)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"type":"Root","args":[{"type":"Str","name":"\"a\"","pos":0,"row":1,"col":1},{"type":"Empty","pos":3,"row":1,"col":4}]}`, string(b))
+
+	var got AST
+	err = json.Unmarshal(b, &got)
+
+	assert.NoError(t, err)
+	assert.Equal(t, ast, &got)
+}
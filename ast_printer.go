@@ -2,200 +2,489 @@ package calm
 
 import (
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode"
 )
 
+// PrintTree prints an AST in a format. It panics if the
+// format is unknown and it ignores write errors. See
+// FprintTree.
 func PrintTree(out io.Writer, format string, a *AST) {
-	switch format {
-	case "json":
-		Walk(&treePrintVisitor{out: out, printer: &treeJsonPrint{}}, a)
-	case "json-inline":
-		Walk(&treePrintVisitor{out: out, printer: &treeJsonInlinePrint{}}, a)
-	case "short":
-		Walk(&treePrintVisitor{out: out, printer: &treeShortPrint{}}, a)
-	case "short-inline":
-		Walk(&treePrintVisitor{out: out, printer: &treeShortInlinePrint{}}, a)
-	case "nice":
-		Walk(&treePrintVisitor{out: out, printer: &treeNicePrint{}}, a)
-	default:
-		panic("unknown format " + format)
+	if err := FprintTree(out, format, a); errors.Is(err, errUnknownFormat) {
+		panic(err)
 	}
 }
 
+// FprintTree prints an AST in a format. It returns an
+// error if the format is unknown or if writing fails.
+// The formats are json, json-inline, json-full,
+// json-full-inline, short, short-inline, nice, dot,
+// mermaid, sexpr, xml and the ones given to
+// RegisterFormat. The json-full formats are the json
+// ones with the positions, kinds and trivia of the
+// nodes too; ParseAST reads them back.
+func FprintTree(out io.Writer, format string, a *AST) error {
+	p, ok := lookupFormat(format)
+	if !ok {
+		return fmt.Errorf("%w %s", errUnknownFormat, format)
+	}
+	return p.Print(out, a)
+}
+
+var errUnknownFormat = errors.New("unknown format")
+
+// Printer prints an AST in a format.
+type Printer interface {
+	Print(io.Writer, *AST) error
+}
+
+// PrinterFunc makes a Printer from a function.
+type PrinterFunc func(io.Writer, *AST) error
+
+func (f PrinterFunc) Print(out io.Writer, a *AST) error {
+	return f(out, a)
+}
+
+// RegisterFormat adds a format to PrintTree and
+// FprintTree, or replaces the one with that name.
+func RegisterFormat(name string, p Printer) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	formats[name] = p
+}
+
+func lookupFormat(name string) (Printer, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	p, ok := formats[name]
+	return p, ok
+}
+
+var formatsMu sync.RWMutex
+
+// formats are the formats of PrintTree by name.
+var formats = map[string]Printer{
+	"json":             treeJson(jsonName),
+	"json-inline":      treeJsonInline(jsonName),
+	"json-full":        treeJson(jsonFields),
+	"json-full-inline": treeJsonInline(jsonFields),
+	"short":            treeShort,
+	"short-inline":     treeShortInline,
+	"nice":             treeNice,
+	"sexpr":            treeSexpr,
+	"xml":              treeXml,
+	"dot":              plainFormat(printDot),
+	"mermaid":          plainFormat(printMermaid),
+}
+
+// plainFormat makes a format from a function that
+// ignores write errors, as the built-in ones do.
+func plainFormat(f func(io.Writer, *AST)) Printer {
+	return PrinterFunc(func(out io.Writer, a *AST) error {
+		w := &errWriter{w: out}
+		f(w, a)
+		return w.err
+	})
+}
+
+// errWriter keeps the first write error
+// and stops writing after it.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.err = err
+	return n, err
+}
+
+// treeFormat is a Printer that prints a tree
+// node by node with the functions of a format.
+type treeFormat struct {
+	open      func(v *treePrintVisitor, n *AST)
+	close     func(v *treePrintVisitor, n *AST)
+	argsOpen  func(v *treePrintVisitor, n *AST)
+	argsClose func(v *treePrintVisitor, n *AST)
+	argsSep   func(v *treePrintVisitor, n *AST)
+}
+
+func (f treeFormat) Print(out io.Writer, a *AST) error {
+	w := &errWriter{w: out}
+	Walk(&treePrintVisitor{out: w, format: f}, a)
+	return w.err
+}
+
 type treePrintVisitor struct {
-	out     io.Writer
-	dep     int
-	pad     string
-	printer treePrinter
+	out    io.Writer
+	dep    int
+	pad    string
+	format treeFormat
 }
 
 func (v *treePrintVisitor) Visit(n *AST) Visitor {
 	pad := strings.Repeat("    ", v.dep)
 	v.pad = pad
-	v.printer.WriteOpen(v, n)
+	v.format.open(v, n)
 	if len(n.Args) > 0 {
-		v.printer.WriteArgsOpen(v)
+		v.format.argsOpen(v, n)
 		v.dep++
 		for i, a := range n.Args {
 			if i > 0 {
-				v.printer.WriteArgsSep(v)
+				v.format.argsSep(v, n)
 			}
 			Walk(v, a)
 		}
 		v.dep--
 		v.pad = pad
-		v.printer.WriteArgsClose(v)
+		v.format.argsClose(v, n)
 	}
-	v.printer.WriteClose(v)
+	v.format.close(v, n)
 	return nil
 }
 
-type treePrinter interface {
-	WriteOpen(*treePrintVisitor, *AST)
-	WriteClose(*treePrintVisitor)
-	WriteArgsOpen(*treePrintVisitor)
-	WriteArgsClose(*treePrintVisitor)
-	WriteArgsSep(*treePrintVisitor)
-}
-
-type treeShortPrint struct{}
-
-func (treeShortPrint) WriteOpen(v *treePrintVisitor, n *AST) {
-	fmt.Fprint(v.out, v.pad)
-	fmt.Fprintf(v.out, "%s", n.Type)
-	if n.Name.Text != "" {
-		fmt.Fprintf(v.out, " %s", n.Name.Text)
-	}
+var treeShort = treeFormat{
+	open: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprint(v.out, v.pad)
+		fmt.Fprintf(v.out, "%s", n.Type)
+		if n.Name.Text != "" {
+			fmt.Fprintf(v.out, " %s", n.Name.Text)
+		}
+	},
+	argsOpen: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprint(v.out, " [")
+		fmt.Fprint(v.out, "\n")
+	},
+	argsClose: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprint(v.out, v.pad)
+		fmt.Fprint(v.out, "]")
+	},
+	close: func(v *treePrintVisitor, n *AST) {
+		if v.dep > 0 {
+			fmt.Fprint(v.out, "\n")
+		}
+	},
+	argsSep: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprint(v.out, "")
+	},
 }
 
-func (treeShortPrint) WriteArgsOpen(v *treePrintVisitor) {
-	fmt.Fprint(v.out, " [")
-	fmt.Fprint(v.out, "\n")
+var treeShortInline = treeFormat{
+	open: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprintf(v.out, "%s", n.Type)
+		if n.Name.Text != "" {
+			fmt.Fprintf(v.out, " %s", n.Name.Text)
+		}
+	},
+	argsOpen: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprint(v.out, " [ ")
+	},
+	argsClose: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprint(v.out, " ]")
+	},
+	close: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprint(v.out, "")
+	},
+	argsSep: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprint(v.out, ", ")
+	},
 }
 
-func (treeShortPrint) WriteArgsClose(v *treePrintVisitor) {
-	fmt.Fprint(v.out, v.pad)
-	fmt.Fprint(v.out, "]")
-}
+// treeNice is treeShortInline with
+// the name in place of the type.
+var treeNice = func() treeFormat {
+	f := treeShortInline
+	f.open = func(v *treePrintVisitor, n *AST) {
+		if n.Name.Text != "" {
+			fmt.Fprintf(v.out, "%s", n.Name.Text)
+		} else {
+			fmt.Fprintf(v.out, "%s", n.Type)
+		}
+	}
+	return f
+}()
 
-func (treeShortPrint) WriteClose(v *treePrintVisitor) {
-	if v.dep > 0 {
-		fmt.Fprint(v.out, "\n")
+// treeJson prints the type and the
+// given fields of the nodes as JSON.
+func treeJson(fields func(*AST) []string) treeFormat {
+	return treeFormat{
+		open: func(v *treePrintVisitor, n *AST) {
+			fmt.Fprint(v.out, v.pad)
+			fmt.Fprint(v.out, v.pad)
+			fmt.Fprint(v.out, "{")
+			fmt.Fprint(v.out, "\n")
+			fmt.Fprint(v.out, v.pad)
+			fmt.Fprint(v.out, v.pad)
+			t, _ := json.Marshal(n.Type)
+			fmt.Fprintf(v.out, `    "type": %s`, t)
+			for _, f := range fields(n) {
+				fmt.Fprint(v.out, ",")
+				fmt.Fprint(v.out, "\n")
+				fmt.Fprint(v.out, v.pad)
+				fmt.Fprint(v.out, v.pad)
+				fmt.Fprintf(v.out, "    %s", f)
+			}
+		},
+		argsOpen: func(v *treePrintVisitor, n *AST) {
+			fmt.Fprint(v.out, ",")
+			fmt.Fprint(v.out, "\n")
+			fmt.Fprint(v.out, v.pad)
+			fmt.Fprint(v.out, v.pad)
+			fmt.Fprint(v.out, `    "args": [`)
+			fmt.Fprint(v.out, "\n")
+		},
+		argsClose: func(v *treePrintVisitor, n *AST) {
+			fmt.Fprint(v.out, "\n")
+			fmt.Fprint(v.out, v.pad)
+			fmt.Fprint(v.out, v.pad)
+			fmt.Fprint(v.out, "    ]")
+		},
+		close: func(v *treePrintVisitor, n *AST) {
+			fmt.Fprint(v.out, "\n")
+			fmt.Fprint(v.out, v.pad)
+			fmt.Fprint(v.out, v.pad)
+			fmt.Fprint(v.out, "}")
+		},
+		argsSep: func(v *treePrintVisitor, n *AST) {
+			fmt.Fprint(v.out, ",")
+			fmt.Fprint(v.out, "\n")
+		},
 	}
 }
 
-func (treeShortPrint) WriteArgsSep(v *treePrintVisitor) {
-	fmt.Fprint(v.out, "")
+// treeJsonInline is treeJson in one line.
+func treeJsonInline(fields func(*AST) []string) treeFormat {
+	return treeFormat{
+		open: func(v *treePrintVisitor, n *AST) {
+			fmt.Fprint(v.out, "{")
+			t, _ := json.Marshal(n.Type)
+			fmt.Fprintf(v.out, ` "type": %s`, t)
+			for _, f := range fields(n) {
+				fmt.Fprintf(v.out, ", %s", f)
+			}
+		},
+		argsOpen: func(v *treePrintVisitor, n *AST) {
+			fmt.Fprint(v.out, `, "args": [`)
+		},
+		argsClose: func(v *treePrintVisitor, n *AST) {
+			fmt.Fprint(v.out, "]")
+		},
+		close: func(v *treePrintVisitor, n *AST) {
+			fmt.Fprint(v.out, " }")
+		},
+		argsSep: func(v *treePrintVisitor, n *AST) {
+			fmt.Fprint(v.out, ", ")
+		},
+	}
 }
 
-type treeShortInlinePrint struct{}
-
-func (treeShortInlinePrint) WriteOpen(v *treePrintVisitor, n *AST) {
-	fmt.Fprintf(v.out, "%s", n.Type)
-	if n.Name.Text != "" {
-		fmt.Fprintf(v.out, " %s", n.Name.Text)
+// jsonName returns the JSON field
+// of the name of a node, if any.
+func jsonName(n *AST) []string {
+	if n.Name.Text == "" {
+		return nil
 	}
+	b, _ := json.Marshal(n.Name.Text)
+	return []string{`"name": ` + string(b)}
 }
 
-func (treeShortInlinePrint) WriteArgsOpen(v *treePrintVisitor) {
-	fmt.Fprint(v.out, " [ ")
+// jsonFields returns the JSON fields of the name,
+// position, kind, span and trivia of a node. The span is [start pos, row,
+// col, end pos, row, col].
+func jsonFields(n *AST) []string {
+	t := n.Name
+	var fs []string
+	if t.Text != "" {
+		b, _ := json.Marshal(t.Text)
+		fs = append(fs, `"name": `+string(b))
+	}
+	if t.Row > 0 {
+		fs = append(fs, fmt.Sprintf(`"pos": %d`, t.Pos), fmt.Sprintf(`"row": %d`, t.Row), fmt.Sprintf(`"col": %d`, t.Col))
+	}
+	if t.Kind != "" {
+		b, _ := json.Marshal(t.Kind)
+		fs = append(fs, `"kind": `+string(b))
+	}
+	if s, e := n.Span.Start, n.Span.End; s.row > 0 {
+		fs = append(fs, fmt.Sprintf(`"span": [%d, %d, %d, %d, %d, %d]`, s.pos, s.row, s.col, e.pos, e.row, e.col))
+	}
+	if n.Leading != "" {
+		b, _ := json.Marshal(n.Leading)
+		fs = append(fs, `"leading": `+string(b))
+	}
+	if n.Trailing != "" {
+		b, _ := json.Marshal(n.Trailing)
+		fs = append(fs, `"trailing": `+string(b))
+	}
+	return fs
 }
 
-func (treeShortInlinePrint) WriteArgsClose(v *treePrintVisitor) {
-	fmt.Fprint(v.out, " ]")
+var treeSexpr = treeFormat{
+	open: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprintf(v.out, "(%s", n.Type)
+		if n.Name.Text != "" {
+			fmt.Fprintf(v.out, " %s", sexprAtom(n.Name.Text))
+		}
+	},
+	argsOpen: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprint(v.out, " ")
+	},
+	argsClose: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprint(v.out, "")
+	},
+	close: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprint(v.out, ")")
+	},
+	argsSep: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprint(v.out, " ")
+	},
 }
 
-func (treeShortInlinePrint) WriteClose(v *treePrintVisitor) {
-	fmt.Fprint(v.out, "")
+// sexprAtom quotes a text that
+// is not a valid S-expression atom.
+func sexprAtom(s string) string {
+	if strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || !unicode.IsPrint(r) || strings.ContainsRune(`()";'\`, r)
+	}) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
 }
 
-func (treeShortInlinePrint) WriteArgsSep(v *treePrintVisitor) {
-	fmt.Fprint(v.out, ", ")
+var treeXml = treeFormat{
+	open: func(v *treePrintVisitor, n *AST) {
+		tag := xmlTag(n)
+		fmt.Fprintf(v.out, "%s<%s", v.pad, tag)
+		if tag != n.Type {
+			fmt.Fprintf(v.out, ` type="%s"`, xmlEscape(n.Type))
+		}
+		if n.Name.Text != "" {
+			fmt.Fprintf(v.out, ` name="%s"`, xmlEscape(n.Name.Text))
+		}
+		if n.Name.Row > 0 {
+			fmt.Fprintf(v.out, ` pos="%d" row="%d" col="%d"`, n.Name.Pos, n.Name.Row, n.Name.Col)
+		}
+		if n.Name.Kind != "" {
+			fmt.Fprintf(v.out, ` kind="%s"`, xmlEscape(n.Name.Kind))
+		}
+		if len(n.Args) == 0 {
+			fmt.Fprint(v.out, "/>")
+		}
+	},
+	argsOpen: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprint(v.out, ">\n")
+	},
+	argsClose: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprintf(v.out, "\n%s</%s>", v.pad, xmlTag(n))
+	},
+	close: func(v *treePrintVisitor, n *AST) {},
+	argsSep: func(v *treePrintVisitor, n *AST) {
+		fmt.Fprint(v.out, "\n")
+	},
 }
 
-type treeNicePrint struct{ treeShortInlinePrint }
-
-func (treeNicePrint) WriteOpen(v *treePrintVisitor, n *AST) {
-	if n.Name.Text != "" {
-		fmt.Fprintf(v.out, "%s", n.Name.Text)
-	} else {
-		fmt.Fprintf(v.out, "%s", n.Type)
+// xmlTag returns the element name of a node: its
+// type, or node when the type is not a valid name.
+func xmlTag(n *AST) string {
+	if xmlName(n.Type) {
+		return n.Type
 	}
+	return "node"
 }
 
-type treeJsonPrint struct{}
-
-func (treeJsonPrint) WriteOpen(v *treePrintVisitor, n *AST) {
-	fmt.Fprint(v.out, v.pad)
-	fmt.Fprint(v.out, v.pad)
-	fmt.Fprint(v.out, "{")
-	fmt.Fprint(v.out, "\n")
-	fmt.Fprint(v.out, v.pad)
-	fmt.Fprint(v.out, v.pad)
-	fmt.Fprintf(v.out, `    "type": "%s"`, n.Type)
-	if n.Name.Text != "" {
-		b, _ := json.Marshal(n.Name.Text)
-		fmt.Fprint(v.out, ",")
-		fmt.Fprint(v.out, "\n")
-		fmt.Fprint(v.out, v.pad)
-		fmt.Fprint(v.out, v.pad)
-		fmt.Fprintf(v.out, `    "name": %s`, b)
+// xmlName tells if s is a valid XML element name.
+func xmlName(s string) bool {
+	for i, r := range s {
+		if !(unicode.IsLetter(r) || r == '_' || i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.')) {
+			return false
+		}
 	}
+	return s != "" && !strings.HasPrefix(strings.ToLower(s), "xml")
 }
 
-func (treeJsonPrint) WriteArgsOpen(v *treePrintVisitor) {
-	fmt.Fprint(v.out, ",")
-	fmt.Fprint(v.out, "\n")
-	fmt.Fprint(v.out, v.pad)
-	fmt.Fprint(v.out, v.pad)
-	fmt.Fprint(v.out, `    "args": [`)
-	fmt.Fprint(v.out, "\n")
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
 }
 
-func (treeJsonPrint) WriteArgsClose(v *treePrintVisitor) {
-	fmt.Fprint(v.out, "\n")
-	fmt.Fprint(v.out, v.pad)
-	fmt.Fprint(v.out, v.pad)
-	fmt.Fprint(v.out, "    ]")
+// printDot prints the tree as a Graphviz DOT graph.
+func printDot(out io.Writer, a *AST) {
+	fmt.Fprint(out, "digraph AST {")
+	Walk(&treeGraphVisitor{
+		out: out,
+		node: func(id int, label []string) {
+			r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+			for i, l := range label {
+				label[i] = r.Replace(l)
+			}
+			fmt.Fprintf(out, "\n    n%d [label=\"%s\"];", id, strings.Join(label, `\n`))
+		},
+		edge: func(from, to int) {
+			fmt.Fprintf(out, "\n    n%d -> n%d;", from, to)
+		},
+	}, a)
+	fmt.Fprint(out, "\n}")
 }
 
-func (treeJsonPrint) WriteClose(v *treePrintVisitor) {
-	fmt.Fprint(v.out, "\n")
-	fmt.Fprint(v.out, v.pad)
-	fmt.Fprint(v.out, v.pad)
-	fmt.Fprint(v.out, "}")
+// printMermaid prints the tree as a Mermaid flowchart.
+func printMermaid(out io.Writer, a *AST) {
+	fmt.Fprint(out, "graph TD")
+	Walk(&treeGraphVisitor{
+		out: out,
+		node: func(id int, label []string) {
+			r := strings.NewReplacer("#", "#35;", `"`, "#quot;", "<", "#lt;", ">", "#gt;", "\n", "#92;n")
+			for i, l := range label {
+				label[i] = r.Replace(l)
+			}
+			fmt.Fprintf(out, "\n    n%d[\"%s\"]", id, strings.Join(label, "<br/>"))
+		},
+		edge: func(from, to int) {
+			fmt.Fprintf(out, "\n    n%d --> n%d", from, to)
+		},
+	}, a)
 }
 
-func (treeJsonPrint) WriteArgsSep(v *treePrintVisitor) {
-	fmt.Fprint(v.out, ",")
-	fmt.Fprint(v.out, "\n")
+// treeGraphVisitor prints a tree as a graph, each
+// node with an id and an edge from its parent.
+type treeGraphVisitor struct {
+	out  io.Writer
+	ids  []int // Ids of the parents.
+	next int
+	node func(id int, label []string)
+	edge func(from, to int)
 }
 
-type treeJsonInlinePrint struct{}
-
-func (treeJsonInlinePrint) WriteOpen(v *treePrintVisitor, n *AST) {
-	fmt.Fprint(v.out, "{")
-	fmt.Fprintf(v.out, ` "type": "%s"`, n.Type)
+func (v *treeGraphVisitor) Visit(n *AST) Visitor {
+	if n == nil {
+		v.ids = v.ids[:len(v.ids)-1]
+		return nil
+	}
+	id := v.next
+	v.next++
+	label := []string{n.Type}
 	if n.Name.Text != "" {
-		b, _ := json.Marshal(n.Name.Text)
-		fmt.Fprintf(v.out, `, "name": %s`, b)
+		label = append(label, n.Name.Text)
 	}
-}
-
-func (treeJsonInlinePrint) WriteArgsOpen(v *treePrintVisitor) {
-	fmt.Fprint(v.out, `, "args": [`)
-}
-
-func (treeJsonInlinePrint) WriteArgsClose(v *treePrintVisitor) {
-	fmt.Fprint(v.out, "]")
-}
-
-func (treeJsonInlinePrint) WriteClose(v *treePrintVisitor) {
-	fmt.Fprint(v.out, " }")
-}
-
-func (treeJsonInlinePrint) WriteArgsSep(v *treePrintVisitor) {
-	fmt.Fprint(v.out, ", ")
+	if s, e := n.Span.Start, n.Span.End; s.row > 0 {
+		label = append(label, fmt.Sprintf("%d:%d-%d:%d", s.row, s.col, e.row, e.col))
+	} else if n.Name.Row > 0 {
+		label = append(label, fmt.Sprintf("%d:%d", n.Name.Row, n.Name.Col))
+	}
+	v.node(id, label)
+	if len(v.ids) > 0 {
+		v.edge(v.ids[len(v.ids)-1], id)
+	}
+	v.ids = append(v.ids, id)
+	return v
 }
@@ -1,9 +1,15 @@
 package calm
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"testing"
 	"unicode"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func ExamplePrintTree_Short() {
@@ -146,6 +152,162 @@ func ExamplePrintTree_Json_String_Encoding() {
 	// { "type": "Root", "args": [{ "type": "Str", "name": "\"Hello\"" }] }
 }
 
+func ExamplePrintTree_jsonFullInline() {
+
+	var ast AST
+	oks := String(`"`).Leaf("Str").Tree(&ast).Run(New(`"Hello"`))
+
+	fmt.Println(oks)
+	PrintTree(os.Stdout, "json-full-inline", &ast)
+
+	// Output:
+	// true
+	// { "type": "Root", "span": [0, 1, 1, 7, 1, 8], "args": [{ "type": "Str", "name": "\"Hello\"", "pos": 0, "row": 1, "col": 1, "span": [0, 1, 1, 7, 1, 8] }] }
+}
+
+func ExamplePrintTree_dot() {
+
+	var ast AST
+	oks := And(S("x").Leaf("Var"), S("=").Leaf("Op"), String(`"`).Leaf("Str")).Group("Assign").Tree(&ast).Run(New(`x="a"`))
+
+	fmt.Println(oks)
+	PrintTree(os.Stdout, "dot", &ast)
+
+	// Output:
+	// true
+	// digraph AST {
+	//     n0 [label="Root\n1:1-1:6"];
+	//     n1 [label="Assign\n1:1-1:6"];
+	//     n0 -> n1;
+	//     n2 [label="Var\nx\n1:1-1:2"];
+	//     n1 -> n2;
+	//     n3 [label="Op\n=\n1:2-1:3"];
+	//     n1 -> n3;
+	//     n4 [label="Str\n\"a\"\n1:3-1:6"];
+	//     n1 -> n4;
+	// }
+}
+
+func ExamplePrintTree_mermaid() {
+
+	var ast AST
+	oks := And(S("x").Leaf("Var"), S("=").Leaf("Op"), String(`"`).Leaf("Str")).Group("Assign").Tree(&ast).Run(New(`x="a"`))
+
+	fmt.Println(oks)
+	PrintTree(os.Stdout, "mermaid", &ast)
+
+	// Output:
+	// true
+	// graph TD
+	//     n0["Root<br/>1:1-1:6"]
+	//     n1["Assign<br/>1:1-1:6"]
+	//     n0 --> n1
+	//     n2["Var<br/>x<br/>1:1-1:2"]
+	//     n1 --> n2
+	//     n3["Op<br/>=<br/>1:2-1:3"]
+	//     n1 --> n3
+	//     n4["Str<br/>#quot;a#quot;<br/>1:3-1:6"]
+	//     n1 --> n4
+}
+
+func ExamplePrintTree_sexpr() {
+
+	var ast AST
+	oks := parseExpr(&ast)
+
+	fmt.Println(oks)
+	PrintTree(os.Stdout, "sexpr", &ast)
+
+	// Output:
+	// true
+	// (Root (Expr + (Val 2) (Expr + (Expr * (Val 3) (Val 4)) (Expr + (Val 5) (Val 6)))))
+}
+
+func ExamplePrintTree_xml() {
+
+	var ast AST
+	oks := And(S("x").Leaf("Var"), S("=").Leaf("Op"), String(`"`).Leaf("Str")).Group("Assign").Tree(&ast).Run(New(`x="<a>"`))
+
+	fmt.Println(oks)
+	PrintTree(os.Stdout, "xml", &ast)
+
+	// Output:
+	// true
+	// <Root>
+	//     <Assign>
+	//         <Var name="x" pos="0" row="1" col="1"/>
+	//         <Op name="=" pos="1" row="1" col="2"/>
+	//         <Str name="&#34;&lt;a&gt;&#34;" pos="2" row="1" col="3"/>
+	//     </Assign>
+	// </Root>
+}
+
+func TestPrintTree_Sexpr_Quote(t *testing.T) {
+
+	ast := &AST{Type: "Fun", Name: Token{Text: "func"}, Args: []*AST{
+		{Type: "Name", Name: Token{Text: "main"}},
+		{Type: "Str", Name: Token{Text: `"a b"`}},
+		{Type: "Body"},
+	}}
+
+	assert.Equal(t, `(Fun func (Name main) (Str "\"a b\"") (Body))`, ast.Print("sexpr"))
+}
+
+func TestPrintTree_Xml_Names(t *testing.T) {
+
+	ast := &AST{Type: "+", Args: []*AST{{Type: "xmlData", Name: Token{Text: "a", Kind: "Ident"}}}}
+
+	exp := "<node type=\"+\">\n    <node type=\"xmlData\" name=\"a\" kind=\"Ident\"/>\n</node>"
+
+	assert.Equal(t, exp, ast.Print("xml"))
+}
+
+func TestFprintTree(t *testing.T) {
+
+	var b strings.Builder
+	err := FprintTree(&b, "short-inline", &AST{Type: "Root", Args: []*AST{{Type: "A"}}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Root [ A ]", b.String())
+
+	err = FprintTree(&b, "unknown", &AST{})
+
+	assert.EqualError(t, err, "unknown format unknown")
+	assert.PanicsWithError(t, "unknown format unknown", func() { PrintTree(&b, "unknown", &AST{}) })
+
+	err = FprintTree(failWriter{}, "json", &AST{Type: "Root"})
+
+	assert.EqualError(t, err, "write failed")
+	assert.NotPanics(t, func() { PrintTree(failWriter{}, "json", &AST{Type: "Root"}) })
+
+	var _ func(io.Writer, string, *AST) = PrintTree
+}
+
+func TestAST_Print_UnknownFormat(t *testing.T) {
+
+	assert.PanicsWithError(t, "unknown format jsno", func() { new(AST).Print("jsno") })
+}
+
+func TestRegisterFormat(t *testing.T) {
+
+	RegisterFormat("types", PrinterFunc(func(w io.Writer, a *AST) error {
+		_, err := io.WriteString(w, types(a))
+		return err
+	}))
+
+	ast := &AST{Type: "Root", Args: []*AST{{Type: "A"}, {Type: "B", Args: []*AST{{Type: "C"}}}}}
+
+	assert.Equal(t, "[A [C]]", ast.Print("types"))
+}
+
+type failWriter struct{}
+
+var errWrite = errors.New("write failed")
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, errWrite
+}
+
 func parseExpr(ast *AST) bool {
 	term, setTerm := Recursive()
 	expr, setExpr := Recursive()
@@ -0,0 +1,376 @@
+package calm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query returns the first node, in depth-first
+// order, that matches a selector, or nil. It returns
+// an error if the selector is invalid. It parses the
+// selector on each call; to query many times, use
+// ParseSelector and Selector.Query.
+func (a *AST) Query(sel string) (*AST, error) {
+	s, err := ParseSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+	return s.Query(a), nil
+}
+
+// QueryAll returns the nodes that match a selector
+// in depth-first order. It is like Query; see
+// Selector.QueryAll.
+func (a *AST) QueryAll(sel string) ([]*AST, error) {
+	s, err := ParseSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+	return s.QueryAll(a), nil
+}
+
+// MustQuery is like Query, but
+// it panics on invalid selectors.
+func (a *AST) MustQuery(sel string) *AST {
+	return mustSelector(sel).Query(a)
+}
+
+// MustQueryAll is like QueryAll, but
+// it panics on invalid selectors.
+func (a *AST) MustQueryAll(sel string) []*AST {
+	return mustSelector(sel).QueryAll(a)
+}
+
+// Selector selects nodes of an AST, like CSS
+// selectors do with HTML elements. For example,
+// "Fun > Body Call[Name=Println]" selects the
+// Call nodes, inside a Body node that is a child
+// of a Fun node, with a Name child with the text
+// Println. A selector is made of:
+//
+//	Type          nodes of a type; * is any type.
+//	A B           B nodes inside A nodes.
+//	A > B         B nodes that are children of A nodes.
+//	A, B          A nodes and B nodes.
+//	[=v]          nodes with the text v; v may be quoted.
+//	[X]           nodes with a child of type X.
+//	[X=v]         nodes with a child of type X with the text v.
+//	[X!=v]        nodes without a child of type X with the text v.
+//	[X^=v]        like [X=v], but the text starts with v.
+//	[X$=v]        like [X=v], but the text ends with v.
+//	[X*=v]        like [X=v], but the text contains v.
+//	:first-child  nodes that are the first child of their parent.
+//	:last-child   nodes that are the last child of their parent.
+//	:nth-child(n) nodes that are the nth child of their parent, from 1;
+//	              n may be an+b, odd or even, as in CSS.
+//	:has(S)       nodes with a node inside them that matches S;
+//	              S may start with > to select children only.
+//	:not(S)       nodes that do not match S.
+//
+// Only the nodes inside the queried node are
+// selected, but A in "A B" may be the queried
+// node itself.
+type Selector struct {
+	alts [][]selStep
+}
+
+// ParseSelector parses a selector. See Selector.
+func ParseSelector(sel string) (*Selector, error) {
+	var ast AST
+	if err := selectorSyntax().Tree(&ast).RunErr(New(sel)); err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", sel, err)
+	}
+	return compileSelector(ast.Args), nil
+}
+
+func mustSelector(sel string) *Selector {
+	s, err := ParseSelector(sel)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Query returns the first node, in depth-first
+// order, inside a that matches the selector, or nil.
+func (s *Selector) Query(a *AST) *AST {
+	v := &queryVisitor{sel: s, free: true, first: true}
+	Walk(v, a)
+	if len(v.out) == 0 {
+		return nil
+	}
+	return v.out[0]
+}
+
+// QueryAll returns the nodes inside a that
+// match the selector in depth-first order.
+func (s *Selector) QueryAll(a *AST) []*AST {
+	v := &queryVisitor{sel: s, free: true}
+	Walk(v, a)
+	return v.out
+}
+
+type queryVisitor struct {
+	sel   *Selector
+	free  bool // The first step may match the queried node.
+	first bool // Stops at the first match.
+	path  []selFrame
+	next  []int // Index of the next child of each node in path.
+	out   []*AST
+}
+
+// selFrame is a node in the path of the visited node.
+type selFrame struct {
+	n      *AST
+	parent *AST
+	i      int
+}
+
+func (v *queryVisitor) Visit(n *AST) Visitor {
+	if n == nil {
+		v.path = v.path[:len(v.path)-1]
+		v.next = v.next[:len(v.next)-1]
+		return nil
+	}
+	if v.first && len(v.out) > 0 {
+		return nil
+	}
+	f := selFrame{n: n}
+	if top := len(v.path) - 1; top >= 0 {
+		f.parent, f.i = v.path[top].n, v.next[top]
+		v.next[top]++
+	}
+	v.path = append(v.path, f)
+	v.next = append(v.next, 0)
+	if len(v.path) > 1 && v.sel.match(v.path, v.free) {
+		v.out = append(v.out, n)
+	}
+	return v
+}
+
+type selStep struct {
+	child bool // Child of the node of the previous step.
+	typ   string
+	preds []func(path []selFrame) bool
+}
+
+// match tells if the last node in path matches the
+// selector. path[0] is the queried node, that the
+// first step may match only when free is true.
+func (s *Selector) match(path []selFrame, free bool) bool {
+	for _, steps := range s.alts {
+		if matchSteps(steps, len(steps)-1, path, free) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSteps(steps []selStep, k int, path []selFrame, free bool) bool {
+	if len(path) == 0 || !free && len(path) == 1 {
+		return false
+	}
+	if !steps[k].is(path) {
+		return false
+	}
+	if k == 0 {
+		return !steps[0].child || len(path) == 2
+	}
+	if steps[k].child {
+		return matchSteps(steps, k-1, path[:len(path)-1], free)
+	}
+	for i := len(path) - 1; i > 0; i-- {
+		if matchSteps(steps, k-1, path[:i], free) {
+			return true
+		}
+	}
+	return false
+}
+
+// is tells if the last node in path matches the step.
+func (s selStep) is(path []selFrame) bool {
+	n := path[len(path)-1].n
+	if s.typ != "*" && s.typ != n.Type {
+		return false
+	}
+	for _, p := range s.preds {
+		if !p(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectorSyntax returns the matcher of the selector syntax.
+func selectorSyntax() MatcherFunc {
+	list, setList := Recursive()
+	sp := SOr(" \t\n").ZeroToMany().quiet()
+	ident := And(Or(F(unicode.IsLetter), S("_")), Or(F(unicode.IsLetter), F(unicode.IsDigit), S("_"), S("-")).ZeroToMany())
+	value := Or(grammarQuoted(`"`), grammarQuoted("'"), Until(Eq("]"))).Named("value")
+	op := Or(S("="), S("!="), S("^="), S("$="), S("*="))
+	nth := Or(
+		S("odd").Leaf("Odd"),
+		S("even").Leaf("Even"),
+		And(R(`[+-]?[0-9]*n`).Leaf("A"), AND(sp, R(`[+-]`).Leaf("Sign"), sp, R(`[0-9]+`).Leaf("B")).ZeroToOne()),
+		R(`[+-]?[0-9]+`).Leaf("B"),
+	).Named("an+b")
+	attr := AND(S("["), sp, ident.Named("attribute").Leaf("Attr").ZeroToOne(), sp, AND(op.Leaf("Op"), sp, value.Leaf("Value")).ZeroToOne(), sp, S("]")).Group("Pred")
+	pseudo := And(S(":"), Or(
+		S("first-child").Leaf("FirstChild"),
+		S("last-child").Leaf("LastChild"),
+		AND(S("nth-child("), sp, nth, sp, S(")")).Group("NthChild"),
+		AND(S("has("), sp, list, sp, S(")")).Group("Has"),
+		AND(S("not("), sp, list, sp, S(")")).Group("Not"),
+	).Named("pseudo-class"))
+	preds := Or(attr, pseudo)
+	typ := Or(S("*"), ident.Named("type")).Leaf("Type")
+	compound := Or(And(typ, preds.ZeroToMany()), preds.OneToMany()).Group("Compound")
+	child := AND(sp, S(">").Leaf("Child"), sp)
+	desc := AND(SOr(" \t\n").OneToMany().quiet(), compound.ahead())
+	seq := And(child.ZeroToOne(), compound, AND(Or(child, desc), compound).ZeroToMany()).Group("Seq")
+	setList(And(seq, AND(sp, S(","), sp, seq).ZeroToMany()))
+	return And(sp, list, sp, Next().Not())
+}
+
+// compileSelector compiles the Seq nodes of a selector.
+func compileSelector(seqs []*AST) *Selector {
+	s := &Selector{}
+	for _, seq := range seqs {
+		var steps []selStep
+		child := false
+		for _, n := range seq.Args {
+			if n.Type == "Child" {
+				child = true
+				continue
+			}
+			step := compileStep(n)
+			step.child, child = child, false
+			steps = append(steps, step)
+		}
+		s.alts = append(s.alts, steps)
+	}
+	return s
+}
+
+// compileStep compiles a Compound node of a selector.
+func compileStep(n *AST) selStep {
+	s := selStep{typ: "*"}
+	for _, a := range n.Args {
+		switch a.Type {
+		case "Type":
+			s.typ = a.Name.Text
+		case "Pred":
+			s.preds = append(s.preds, compileAttr(a))
+		case "FirstChild":
+			s.preds = append(s.preds, func(path []selFrame) bool {
+				f := path[len(path)-1]
+				return f.parent != nil && f.i == 0
+			})
+		case "LastChild":
+			s.preds = append(s.preds, func(path []selFrame) bool {
+				f := path[len(path)-1]
+				return f.parent != nil && f.i == len(f.parent.Args)-1
+			})
+		case "NthChild":
+			a, b := compileNth(a.Args)
+			s.preds = append(s.preds, func(path []selFrame) bool {
+				f := path[len(path)-1]
+				if f.parent == nil {
+					return false
+				}
+				if k := f.i + 1 - b; a != 0 {
+					return k%a == 0 && k/a >= 0
+				}
+				return f.i+1 == b
+			})
+		case "Has":
+			sub := compileSelector(a.Args)
+			s.preds = append(s.preds, func(path []selFrame) bool {
+				v := &queryVisitor{sel: sub, first: true}
+				Walk(v, path[len(path)-1].n)
+				return len(v.out) > 0
+			})
+		case "Not":
+			sub := compileSelector(a.Args)
+			s.preds = append(s.preds, func(path []selFrame) bool {
+				return !sub.match(path, true)
+			})
+		}
+	}
+	return s
+}
+
+// compileNth compiles the an+b of an NthChild node.
+func compileNth(ns []*AST) (a, b int) {
+	sign := 1
+	for _, n := range ns {
+		switch t := n.Name.Text; n.Type {
+		case "Odd":
+			return 2, 1
+		case "Even":
+			return 2, 0
+		case "A":
+			switch t = strings.TrimSuffix(t, "n"); t {
+			case "", "+":
+				a = 1
+			case "-":
+				a = -1
+			default:
+				a, _ = strconv.Atoi(t)
+			}
+		case "Sign":
+			if t == "-" {
+				sign = -1
+			}
+		case "B":
+			b, _ = strconv.Atoi(t)
+			b *= sign
+		}
+	}
+	return a, b
+}
+
+// compileAttr compiles a Pred node of a selector.
+func compileAttr(n *AST) func(path []selFrame) bool {
+	var name, op, value string
+	for _, a := range n.Args {
+		switch a.Type {
+		case "Attr":
+			name = a.Name.Text
+		case "Op":
+			op = a.Name.Text
+		case "Value":
+			value = strings.TrimSpace(a.Name.Text)
+			if value != "" && (value[0] == '"' || value[0] == '\'') {
+				value = unquote(value)
+			}
+		}
+	}
+	test := func(s string) bool {
+		switch op {
+		case "^=":
+			return strings.HasPrefix(s, value)
+		case "$=":
+			return strings.HasSuffix(s, value)
+		case "*=":
+			return strings.Contains(s, value)
+		case "":
+			return true
+		}
+		return s == value
+	}
+	return func(path []selFrame) bool {
+		n := path[len(path)-1].n
+		if name == "" {
+			return test(n.Name.Text) != (op == "!=")
+		}
+		for _, a := range n.Args {
+			if a.Type == name && test(a.Name.Text) {
+				return op != "!="
+			}
+		}
+		return op == "!="
+	}
+}
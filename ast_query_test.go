@@ -0,0 +1,151 @@
+package calm
+
+import (
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryAll(t *testing.T) {
+
+	ast := queryTestAST()
+
+	tt := []struct {
+		sel string
+		exp []string
+	}{
+		{"Call", []string{"Call", "Call", "Call"}},
+		{"Name", []string{"main", "Println", "Print", "helper", "Sprint"}},
+		{"Fun > Name", []string{"main", "helper"}},
+		{"Fun > Body Call[Name=Println]", []string{"Call"}},
+		{"Root > Fun Name", []string{"main", "Println", "Print", "helper", "Sprint"}},
+		{"Root Fun > Name", []string{"main", "helper"}},
+		{"Body > Call > Name", []string{"Println", "Print", "Sprint"}},
+		{"Call Name[=Print]", []string{"Print"}},
+		{`Call Name[="Print"]`, []string{"Print"}},
+		{"Call Name[^=Print]", []string{"Println", "Print"}},
+		{"Name[$=rint]", []string{"Print", "Sprint"}},
+		{"Name[*=rin]", []string{"Println", "Print", "Sprint"}},
+		{"Call > Name[!=Print]", []string{"Println", "Sprint"}},
+		{"Call[Name!=Print] > Name", []string{"Println", "Sprint"}},
+		{"Call[Str] > Str", []string{`"a"`}},
+		{"*[Str]", []string{"Call"}},
+		{"Fun:first-child > Name", []string{"main"}},
+		{"Fun:last-child > Name", []string{"helper"}},
+		{"Call:nth-child(2) > Name", []string{"Print"}},
+		{"Body > :nth-child(1) Name", []string{"Println", "Sprint"}},
+		{"Call:nth-child(odd) > Name", []string{"Println", "Sprint"}},
+		{"Call:nth-child(even) > Name", []string{"Print"}},
+		{"Call:nth-child(2n) > Name", []string{"Print"}},
+		{"Call:nth-child(n + 2) > Name", []string{"Print"}},
+		{"Call:nth-child(-n+1) > Name", []string{"Println", "Sprint"}},
+		{"Call:nth-child(+3) > Name", nil},
+		{"Fun:nth-child(2n-1) > Name", []string{"main"}},
+		{"Fun:has(Call[Name=Sprint]) > Name", []string{"helper"}},
+		{"Fun:has(> Name[=main]) > Name", []string{"main"}},
+		{"Fun:has(> Call) > Name", nil},
+		{"Name:not(Call > *)", []string{"main", "helper"}},
+		{"Name:not(Fun > Name, [^=Print])", []string{"Sprint"}},
+		{"Fun > Name, Str", []string{"main", `"a"`, "helper"}},
+		{"Root", nil},
+		{"Nothing", nil},
+	}
+
+	for _, tc := range tt {
+
+		var names []string
+		for _, n := range ast.MustQueryAll(tc.sel) {
+			if n.Name.Text != "" {
+				names = append(names, n.Name.Text)
+			} else {
+				names = append(names, n.Type)
+			}
+		}
+
+		assert.Equal(t, tc.exp, names, tc.sel)
+	}
+}
+
+func TestQuery(t *testing.T) {
+
+	ast := queryTestAST()
+
+	assert.Equal(t, "Println", ast.MustQuery("Call > Name").Name.Text)
+	assert.Equal(t, "Sprint", ast.MustQuery("Fun:last-child").MustQuery("Name[^=S]").Name.Text)
+	assert.Nil(t, ast.MustQuery("Nothing"))
+
+	n, err := ast.Query("Fun > Name")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "main", n.Name.Text)
+
+	ns, err := ast.QueryAll("Call > Name")
+
+	assert.NoError(t, err)
+	assert.Len(t, ns, 3)
+
+	n, err = ast.Query("A >")
+
+	assert.Nil(t, n)
+	assert.EqualError(t, err, `invalid selector "A >": 1:4: unexpected end of input, expected S("*") or type or S("[") or S(":")`)
+
+	ns, err = ast.QueryAll("A[")
+
+	assert.Nil(t, ns)
+	assert.Error(t, err)
+}
+
+func TestParseSelector(t *testing.T) {
+
+	tt := []struct {
+		sel string
+		err string
+	}{
+		{"A > B C, D[x=1]:first-child", ""},
+		{" A ", ""},
+		{"A >", `invalid selector "A >": 1:4: unexpected end of input, expected S("*") or type or S("[") or S(":")`},
+		{"A[", `invalid selector "A[": 1:3: unexpected end of input, expected attribute or S("=") or S("!=") or S("^=") or S("$=") or S("*=") or S("]")`},
+		{"A:foo", `invalid selector "A:foo": 1:3: unexpected "foo", expected pseudo-class or S(">") or S(",")`},
+		{"A:nth-child(x)", `invalid selector "A:nth-child(x)": 1:13: unexpected "x)", expected an+b`},
+		{"", `invalid selector "": 1:1: unexpected end of input, expected S(">") or S("*") or type or S("[") or S(":")`},
+	}
+
+	for _, tc := range tt {
+
+		_, err := ParseSelector(tc.sel)
+
+		if tc.err == "" {
+			assert.NoError(t, err, tc.sel)
+		} else {
+			assert.EqualError(t, err, tc.err, tc.sel)
+		}
+	}
+
+	assert.Panics(t, func() { new(AST).MustQuery("A >") })
+}
+
+func queryTestAST() *AST {
+
+	src := New(`
+	func main() {
+		fmt.Println("a")
+		fmt.Print()
+	}
+	func helper() {
+		fmt.Sprint()
+	}`)
+
+	ws := SOr(" \t").OneToMany()
+	wz := F(unicode.IsSpace).ZeroToMany()
+	name := F(unicode.IsLetter).OneToMany()
+	strg := String(`"`).Leaf("Str")
+
+	fnCall := And(name.Leaf("Pkg"), S("."), name.Leaf("Name"), S("("), strg.ZeroToOne(), S(")")).Group("Call")
+	fnBody := Or(wz.False(), fnCall).ZeroToMany().Group("Body")
+	fnDefn := S("func").Leaf("Fun").Child(ws, name.Leaf("Name"), wz, S("()"), wz, S("{"), fnBody, S("}"))
+
+	var ast AST
+	Or(wz.False(), fnDefn).ZeroToMany().Tree(&ast).Run(src)
+	return &ast
+}
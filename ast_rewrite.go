@@ -0,0 +1,147 @@
+package calm
+
+// ApplyFunc is called by Apply for each node.
+// See Apply.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses an AST depth-first, calling pre
+// before the children of each node and post after
+// them, and returns the root, that may have been
+// replaced. They can change the tree through the
+// Cursor. If pre returns false, the children of
+// the node and post are skipped. If post returns
+// false, the traversal stops. Either may be nil.
+// The nodes inserted by the cursor are not
+// traversed, but the ones that replace the
+// current node are.
+func Apply(root *AST, pre, post ApplyFunc) (result *AST) {
+	top := &AST{Args: []*AST{root}}
+	defer func() {
+		if r := recover(); r != nil && r != errAbort {
+			panic(r)
+		}
+		result = top.Args[0]
+	}()
+	a := &application{pre: pre, post: post}
+	a.iter.step = 1
+	a.apply(top, true, root)
+	return
+}
+
+// Cursor describes the node being
+// visited by Apply. See Apply.
+type Cursor struct {
+	parent *AST
+	node   *AST
+	iter   *iterator
+	root   bool
+}
+
+// Node returns the current node.
+func (c *Cursor) Node() *AST {
+	return c.node
+}
+
+// Parent returns the parent of the current
+// node or nil if it is the root.
+func (c *Cursor) Parent() *AST {
+	if c.root {
+		return nil
+	}
+	return c.parent
+}
+
+// Index returns the index of the current node in
+// the Args of its parent or -1 if it is the root.
+func (c *Cursor) Index() int {
+	if c.root {
+		return -1
+	}
+	return c.iter.index
+}
+
+// Replace replaces the current node with n.
+// Its children are traversed instead of the
+// children of the replaced node.
+func (c *Cursor) Replace(n *AST) {
+	c.parent.Args[c.iter.index] = n
+	c.node = n
+}
+
+// Delete deletes the current node from its parent.
+// It panics if the current node is the root.
+func (c *Cursor) Delete() {
+	c.mustChild("Delete")
+	i := c.iter.index
+	c.parent.Args = append(c.parent.Args[:i:i], c.parent.Args[i+1:]...)
+	c.iter.step--
+}
+
+// InsertBefore inserts n before the current node in its
+// parent. It panics if the current node is the root.
+func (c *Cursor) InsertBefore(n *AST) {
+	c.mustChild("InsertBefore")
+	c.parent.Args = insert(c.parent.Args, c.iter.index, n)
+	c.iter.index++
+}
+
+// InsertAfter inserts n after the current node in its
+// parent. It panics if the current node is the root.
+func (c *Cursor) InsertAfter(n *AST) {
+	c.mustChild("InsertAfter")
+	c.parent.Args = insert(c.parent.Args, c.iter.index+1, n)
+	c.iter.step++
+}
+
+func (c *Cursor) mustChild(op string) {
+	if c.root {
+		panic(op + " on the root node")
+	}
+}
+
+func insert(args []*AST, i int, n *AST) []*AST {
+	out := make([]*AST, 0, len(args)+1)
+	out = append(out, args[:i]...)
+	out = append(out, n)
+	return append(out, args[i:]...)
+}
+
+var errAbort = new(int)
+
+// iterator is the position of
+// the current node in its parent.
+type iterator struct {
+	index, step int
+}
+
+type application struct {
+	pre, post ApplyFunc
+	cursor    Cursor
+	iter      iterator
+}
+
+func (a *application) apply(parent *AST, root bool, n *AST) {
+	saved := a.cursor
+	a.cursor = Cursor{parent: parent, node: n, iter: &a.iter, root: root}
+	if a.pre == nil || a.pre(&a.cursor) {
+		a.applyArgs(a.cursor.node)
+		if a.post != nil && !a.post(&a.cursor) {
+			panic(errAbort)
+		}
+	}
+	a.cursor = saved
+}
+
+func (a *application) applyArgs(parent *AST) {
+	if parent == nil {
+		return
+	}
+	saved := a.iter
+	a.iter.index = 0
+	for a.iter.index < len(parent.Args) {
+		a.iter.step = 1
+		a.apply(parent, false, parent.Args[a.iter.index])
+		a.iter.index += a.iter.step
+	}
+	a.iter = saved
+}
@@ -0,0 +1,114 @@
+package calm
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply_Fold(t *testing.T) {
+
+	// 1 + 2 * 3
+	ast := &AST{Type: "Op", Name: Token{Text: "+"}, Args: []*AST{
+		{Type: "Num", Name: Token{Text: "1"}},
+		{Type: "Op", Name: Token{Text: "*"}, Args: []*AST{
+			{Type: "Num", Name: Token{Text: "2"}},
+			{Type: "Num", Name: Token{Text: "3"}},
+		}},
+	}}
+
+	res := Apply(ast, nil, func(c *Cursor) bool {
+		n := c.Node()
+		if n.Type == "Op" {
+			a, _ := strconv.Atoi(n.Left().Name.Text)
+			b, _ := strconv.Atoi(n.Right().Name.Text)
+			v := a + b
+			if n.Name.Text == "*" {
+				v = a * b
+			}
+			c.Replace(&AST{Type: "Num", Name: Token{Text: strconv.Itoa(v)}})
+		}
+		return true
+	})
+
+	assert.Equal(t, &AST{Type: "Num", Name: Token{Text: "7"}}, res)
+}
+
+func TestApply_Edit(t *testing.T) {
+
+	ast := &AST{Type: "Root", Args: []*AST{
+		{Type: "A"},
+		{Type: "Comment"},
+		{Type: "B", Args: []*AST{
+			{Type: "Comment"},
+			{Type: "C"},
+		}},
+		{Type: "Comment"},
+	}}
+
+	res := Apply(ast, func(c *Cursor) bool {
+		switch c.Node().Type {
+		case "Comment":
+			c.Delete()
+			return false
+		case "A":
+			c.InsertBefore(&AST{Type: "Before"})
+			c.InsertAfter(&AST{Type: "After"})
+		case "C":
+			c.Replace(&AST{Type: "D", Args: []*AST{{Type: "Comment"}, {Type: "E"}}})
+		}
+		return true
+	}, nil)
+
+	assert.Equal(t, "[Before A After [[E]]]", types(res))
+	assert.Equal(t, "D", res.Args[3].Left().Type)
+}
+
+func TestApply_Cursor(t *testing.T) {
+
+	ast := &AST{Type: "Root", Args: []*AST{
+		{Type: "A", Args: []*AST{{Type: "B"}, {Type: "C"}}},
+	}}
+
+	var got []string
+	Apply(ast, func(c *Cursor) bool {
+		parent := "nil"
+		if c.Parent() != nil {
+			parent = c.Parent().Type
+		}
+		got = append(got, c.Node().Type+" "+parent+" "+strconv.Itoa(c.Index()))
+		return true
+	}, nil)
+
+	assert.Equal(t, []string{"Root nil -1", "A Root 0", "B A 0", "C A 1"}, got)
+}
+
+func TestApply_Stop(t *testing.T) {
+
+	ast := &AST{Type: "Root", Args: []*AST{{Type: "A"}, {Type: "B"}, {Type: "C"}}}
+
+	var got []string
+	res := Apply(ast, nil, func(c *Cursor) bool {
+		got = append(got, c.Node().Type)
+		return c.Node().Type != "B"
+	})
+
+	assert.Equal(t, []string{"A", "B"}, got)
+	assert.Equal(t, ast, res)
+}
+
+func TestApply_Root(t *testing.T) {
+
+	ast := &AST{Type: "Root"}
+
+	res := Apply(ast, func(c *Cursor) bool {
+		c.Replace(&AST{Type: "New"})
+		return true
+	}, nil)
+
+	assert.Equal(t, &AST{Type: "New"}, res)
+	assert.PanicsWithValue(t, "Delete on the root node", func() {
+		Apply(ast, func(c *Cursor) bool { c.Delete(); return true }, nil)
+	})
+}
@@ -316,3 +316,119 @@ func TestGroup_Inside_Group(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, exp, ast.Print("short-inline"))
 }
+
+func TestSpan(t *testing.T) {
+
+	// Given.
+
+	src := "func One() {\n\tA()\n\tB()\n}"
+
+	// When.
+
+	ws := SOr(" \t").OneToMany()
+	wz := F(unicode.IsSpace).ZeroToMany()
+	name := F(unicode.IsLetter).OneToMany()
+	fnCall := And(name.Leaf("Call"), S("()"))
+	fnBody := Or(wz.False(), fnCall).ZeroToMany().Group("Body")
+	fnDefn := S("func").Leaf("Func").Child(ws, name.Leaf("Name"), S("()"), wz, S("{"), fnBody, S("}"))
+
+	var ast AST
+	ok := fnDefn.Tree(&ast).Run(New(src))
+
+	// Then.
+
+	fn := ast.MustQuery("Func")
+	body := ast.MustQuery("Body")
+
+	assert.True(t, ok)
+	assert.Equal(t, src, ast.Text(src))
+	assert.Equal(t, src, fn.Text(src))
+	assert.Equal(t, "One", fn.MustQuery("Name").Text(src))
+	assert.Equal(t, "\n\tA()\n\tB()\n", body.Text(src))
+	assert.Equal(t, 12, body.Span.Start.Pos())
+	assert.Equal(t, 1, body.Span.Start.Row())
+	assert.Equal(t, 13, body.Span.Start.Col())
+	assert.Equal(t, 4, body.Span.End.Row())
+	assert.Equal(t, 1, body.Span.End.Col())
+}
+
+func TestSpan_Root(t *testing.T) {
+
+	tt := []struct {
+		in  string
+		ex  []string
+		sel string
+	}{
+		{"1+2", []string{"1+2"}, "Op"},
+		{"1+2*3", []string{"1+2*3", "2*3"}, "Op"},
+		{"(1+2)*3", []string{"(1+2)*3", "1+2"}, "Op"},
+		{"(1+2)*3", []string{"1", "2", "3"}, "Num"},
+	}
+
+	for _, tc := range tt {
+
+		expr, setExpr := Recursive()
+		term, setTerm := Recursive()
+		value := Or(F(unicode.IsDigit).Leaf("Num"), And(S("("), expr, S(")")))
+		setTerm(Or(Root(value, S("*").Leaf("Op"), term), value))
+		setExpr(Or(Root(term, S("+").Leaf("Op"), expr), term))
+
+		var ast AST
+		expr.Tree(&ast).Run(New(tc.in))
+
+		var got []string
+		for _, n := range ast.MustQueryAll(tc.sel) {
+			got = append(got, n.Text(tc.in))
+		}
+
+		assert.Equal(t, tc.ex, got, tc.in)
+	}
+}
+
+func TestSpan_Operators(t *testing.T) {
+
+	num := F(unicode.IsDigit).Leaf("Num")
+	expr := Operators(num).
+		Prefix(S("-").Leaf("Neg"), 3).
+		InfixL(S("+").Leaf("Add"), 1).
+		InfixL(S("*").Leaf("Mul"), 2).
+		Matcher()
+
+	src := "-1+2*3"
+
+	var ast AST
+	ok := expr.Tree(&ast).Run(New(src))
+
+	assert.True(t, ok)
+	assert.Equal(t, "-1+2*3", ast.MustQuery("Add").Text(src))
+	assert.Equal(t, "2*3", ast.MustQuery("Mul").Text(src))
+	assert.Equal(t, "-1", ast.MustQuery("Neg").Text(src))
+}
+
+func TestSpan_Child(t *testing.T) {
+
+	// The Child matcher builds no node, so
+	// the span of the older sibling is kept.
+	m := And(S("a").Leaf("A"), S("b").Child(S("c").Leaf("C")))
+
+	src := "abc"
+
+	var ast AST
+	ok := m.Tree(&ast).Run(New(src))
+
+	assert.True(t, ok)
+	assert.Equal(t, "a", ast.MustQuery("A").Text(src))
+	assert.Equal(t, "c", ast.MustQuery("C").Text(src))
+}
+
+func TestSpan_Memo(t *testing.T) {
+
+	num := F(unicode.IsDigit).OneToMany().Leaf("Num").Memo()
+	m := Or(AND(num, S("x")), AND(num, S("y")))
+
+	var ast AST
+	ok := m.Tree(&ast).Run(New("12y"))
+
+	assert.True(t, ok)
+	assert.Equal(t, Span{Mark{pos: 0, row: 1, col: 1}, Mark{pos: 2, row: 1, col: 3}}, ast.Left().Span)
+}
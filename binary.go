@@ -0,0 +1,151 @@
+package calm
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Byte tests if the current byte equals
+// b and moves the position if true. Like
+// the other binary matchers, it moves one
+// byte at a time even when the code does
+// not scan bytes.
+func Byte(b byte) MatcherFunc {
+	exp := fmt.Sprintf("Byte(0x%02x)", b)
+	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
+		if s, ok := c.peek(1); ok && s[0] == b {
+			c.advance(s)
+			return true
+		}
+		c.expect(exp)
+		return false
+	}
+}
+
+// Bytes matches any n bytes.
+func Bytes(n int) MatcherFunc {
+	exp := fmt.Sprintf("Bytes(%d)", n)
+	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
+		if s, ok := c.peek(n); ok {
+			c.advance(s)
+			return true
+		}
+		c.expect(exp)
+		return false
+	}
+}
+
+// Take matches a number of bytes given by a
+// reference, which is read when matching. It
+// is used for length-prefixed data, for example
+// And(U16BE(&n), Take(&n)).
+func Take[T ~int | ~uint8 | ~uint16 | ~uint32 | ~uint64](n *T) MatcherFunc {
+	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
+		if s, ok := c.peek(int(*n)); ok {
+			c.advance(s)
+			return true
+		}
+		c.expect("Take()")
+		return false
+	}
+}
+
+// U16LE matches a little endian uint16
+// and stores it in v, when v is not nil.
+func U16LE(v *uint16) MatcherFunc {
+	return fixed("U16LE()", 2, func(s []byte) {
+		if v != nil {
+			*v = binary.LittleEndian.Uint16(s)
+		}
+	})
+}
+
+// U16BE matches a big endian uint16
+// and stores it in v, when v is not nil.
+func U16BE(v *uint16) MatcherFunc {
+	return fixed("U16BE()", 2, func(s []byte) {
+		if v != nil {
+			*v = binary.BigEndian.Uint16(s)
+		}
+	})
+}
+
+// U32LE matches a little endian uint32
+// and stores it in v, when v is not nil.
+func U32LE(v *uint32) MatcherFunc {
+	return fixed("U32LE()", 4, func(s []byte) {
+		if v != nil {
+			*v = binary.LittleEndian.Uint32(s)
+		}
+	})
+}
+
+// U32BE matches a big endian uint32
+// and stores it in v, when v is not nil.
+func U32BE(v *uint32) MatcherFunc {
+	return fixed("U32BE()", 4, func(s []byte) {
+		if v != nil {
+			*v = binary.BigEndian.Uint32(s)
+		}
+	})
+}
+
+// Varint matches an unsigned LEB128 varint, as
+// in Protocol Buffers, and stores it in v, when
+// v is not nil.
+func Varint(v *uint64) MatcherFunc {
+	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
+		s, ok := c.peek(binary.MaxVarintLen64)
+		if !ok {
+			s, _ = c.peek(c.buffered())
+		}
+		x, n := binary.Uvarint([]byte(s))
+		if n <= 0 {
+			c.expect("Varint()")
+			return false
+		}
+		if v != nil {
+			*v = x
+		}
+		c.advance(s[:n])
+		return true
+	}
+}
+
+// fixed matches n bytes and calls f with them.
+func fixed(exp string, n int, f func([]byte)) MatcherFunc {
+	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
+		if s, ok := c.peek(n); ok {
+			f([]byte(s))
+			c.advance(s)
+			return true
+		}
+		c.expect(exp)
+		return false
+	}
+}
+
+// peek returns the next n bytes
+// without moving the position.
+func (c *Code) peek(n int) (string, bool) {
+	c.fill(n)
+	if n >= 0 && c.buffered() >= n {
+		return c.text(c.pos, c.pos+n), true
+	}
+	return "", false
+}
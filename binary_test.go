@@ -0,0 +1,106 @@
+package calm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBytes(t *testing.T) {
+
+	c := NewBytes([]byte{0xff, '\n', 0xfe})
+
+	assert.Equal(t, rune(0xff), c.Curr())
+	c.Next()
+	assert.Equal(t, '\n', c.Curr())
+	c.Next()
+	assert.Equal(t, rune(0xfe), c.Curr())
+	assert.Equal(t, Mark{pos: 2, row: 1, col: 3}, c.Mark())
+	c.Next()
+	assert.False(t, c.More())
+}
+
+func TestBinary(t *testing.T) {
+
+	tt := []struct {
+		in []byte
+		ok bool
+		mf MatcherFunc
+	}{
+		{[]byte{1}, true, Byte(1)},
+		{[]byte{2}, false, Byte(1)},
+		{[]byte{}, false, Byte(1)},
+		{[]byte{1, 2, 3}, true, Bytes(3)},
+		{[]byte{1, 2}, false, Bytes(3)},
+		{[]byte{1, 2}, true, U16LE(nil)},
+		{[]byte{1}, false, U16BE(nil)},
+		{[]byte{1, 2, 3, 4}, true, U32LE(nil)},
+		{[]byte{1, 2, 3}, false, U32BE(nil)},
+		{[]byte{0x7f}, true, Varint(nil)},
+		{[]byte{0xff}, false, Varint(nil)},
+		{[]byte{0xff, 0x01}, true, Varint(nil)},
+	}
+
+	for _, tc := range tt {
+
+		c := NewBytes(tc.in)
+
+		ok := tc.mf.Run(c)
+
+		assert.Equal(t, tc.ok, ok, tc.in)
+	}
+}
+
+func TestBinary_Values(t *testing.T) {
+
+	c := NewBytes([]byte{
+		0x01, 0x02, // U16LE
+		0x01, 0x02, // U16BE
+		0x01, 0x02, 0x03, 0x04, // U32LE
+		0x01, 0x02, 0x03, 0x04, // U32BE
+		0xac, 0x02, // Varint
+	})
+
+	var a, b uint16
+	var x, y uint32
+	var v uint64
+	ok := And(U16LE(&a), U16BE(&b), U32LE(&x), U32BE(&y), Varint(&v)).Run(c)
+
+	assert.True(t, ok)
+	assert.Equal(t, uint16(0x0201), a)
+	assert.Equal(t, uint16(0x0102), b)
+	assert.Equal(t, uint32(0x04030201), x)
+	assert.Equal(t, uint32(0x01020304), y)
+	assert.Equal(t, uint64(300), v)
+	assert.False(t, c.More())
+}
+
+func TestTake(t *testing.T) {
+
+	// A message made of a magic byte, a big
+	// endian length and a length-prefixed payload.
+	c := NewBytes([]byte{0xca, 0x00, 0x05, 'h', 'e', 'l', 'l', 'o', 0xca, 0x00, 0x02, 'h', 'i'})
+
+	var n uint16
+	var msgs []string
+	msg := And(Byte(0xca), U16BE(&n), Take(&n).On(Grabs(&msgs)))
+
+	ok := msg.OneToMany().Run(c)
+
+	assert.True(t, ok)
+	assert.Equal(t, []string{"hello", "hi"}, msgs)
+}
+
+func TestBinary_Text(t *testing.T) {
+
+	c := New("\xff\xc3\xa9é")
+
+	ok := And(Byte(0xff), Byte(0xc3)).Run(c)
+
+	assert.True(t, ok)
+	assert.Equal(t, Mark{pos: 2, row: 1, col: 3}, c.Mark())
+	c.Next()
+	assert.Equal(t, 'é', c.Curr())
+	c.Next()
+	assert.False(t, c.More())
+}
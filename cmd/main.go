@@ -1,14 +1,84 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
 	"unicode"
 
 	. "github.com/ofabricio/calm"
 )
 
+const usage = `Usage:
+
+	calm gen [-pkg name] [-o file] grammar
+
+Gen writes the Go source of a parser for a grammar
+file. See the Grammar function for the syntax.
+`
+
 func main() {
 
+	if len(os.Args) < 2 {
+		hello()
+		return
+	}
+
+	switch os.Args[1] {
+	case "gen":
+		err := gen(os.Args[2:])
+		if err == errUsage {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "calm gen:", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// errUsage is returned when the
+// command is called the wrong way.
+var errUsage = errors.New("usage")
+
+func gen(args []string) error {
+
+	fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+	fs.Usage = func() {}
+	pkg := fs.String("pkg", "parser", "package name of the parser")
+	out := fs.String("o", "", "output file (default stdout)")
+
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	src, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	// The parser is generated before writing
+	// it, so a bad grammar leaves no output.
+	var b bytes.Buffer
+	if err := Generate(&b, *pkg, string(src)); err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err := b.WriteTo(os.Stdout)
+		return err
+	}
+	return os.WriteFile(*out, b.Bytes(), 0o644)
+}
+
+func hello() {
+
 	code := New("Hello, World!")
 
 	var words []string
@@ -1,22 +1,71 @@
 package calm
 
 import (
+	"context"
+	"io"
 	"strings"
 	"unicode/utf8"
 )
 
 func New(src string) *Code {
-	return &Code{src: src, row: 1, col: 1, ast: &AST{Type: "Root"}}
+	return &Code{src: src, row: 1, col: 1, ast: &AST{Type: "Root"}, fail: failure{at: Mark{row: 1, col: 1}, near: snippet(src)}}
+}
+
+// NewReader creates a Code that reads the source
+// from a reader as it is scanned. It only keeps
+// buffered the input from the oldest position a
+// matcher may still go back to. Matchers that
+// call Mark and Back by themselves must be
+// wrapped with Undo to keep the input buffered.
+func NewReader(r io.Reader) *Code {
+	c := New("")
+	c.rd = r
+	c.win = make([]byte, 0, readSize)
+	return c
+}
+
+const readSize = 4096
+
+// NewBytes creates a Code that scans bytes instead
+// of UTF-8 characters, for binary formats. Each byte
+// counts as one column and lines are not counted.
+func NewBytes(src []byte) *Code {
+	c := New(string(src))
+	c.bin = true
+	return c
+}
+
+// NewTokens creates a token code, that scans tokens
+// instead of characters. The tokens usually come
+// from a Lexer, and src is their source code. In
+// a token code the strings to match are compared
+// with whole tokens and Next moves to the next
+// token. The regular expression and binary
+// matchers do not work on tokens.
+func NewTokens(src string, toks []Token) *Code {
+	c := New(src)
+	c.toks = toks
+	c.tokpos()
+	c.fail = c.failure()
+	return c
 }
 
 // Equal tests if the string matches
 // with the current position.
 // It does not advances the position.
 func (c *Code) Equal(s string) bool {
-	if s == "" {
+	if s == "" || c.err != nil {
 		return false
 	}
-	return strings.HasPrefix(c.Tail(), s)
+	if c.toks != nil {
+		return c.More() && c.toks[c.pos].Text == s
+	}
+	c.fill(len(s))
+	if c.win != nil {
+		w := c.win[c.pos-c.off:]
+		return len(w) >= len(s) && string(w[:len(s)]) == s
+	}
+	return strings.HasPrefix(c.src[c.pos:], s)
 }
 
 // Match tests if the string matches
@@ -32,58 +81,241 @@ func (c *Code) Match(s string) bool {
 
 // Mark marks the current position.
 func (c *Code) Mark() Mark {
-	return Mark{pos: c.pos, row: c.row, col: c.col}
+	return Mark{pos: c.pos, row: c.row, col: c.col, ind: c.ind}
 }
 
 // Back sends the position back to a mark.
 func (c *Code) Back(m Mark) {
+	back := c.pos - m.pos
 	c.pos = m.pos
 	c.row = m.row
 	c.col = m.col
+	c.ind = m.ind
+	if back > 0 {
+		c.backtrack()
+		if c.prof != nil {
+			c.prof.rewind(back)
+		}
+	}
 }
 
 // Token returns the token between ini and end.
 func (c *Code) Token(ini, end Mark) Token {
-	return Token{Text: c.src[ini.pos:end.pos], Pos: ini.pos, Row: ini.row, Col: ini.col}
+	if c.toks != nil {
+		return c.tokens(ini, end)
+	}
+	return Token{Text: c.text(ini.pos, end.pos), Pos: ini.pos, Row: ini.row, Col: ini.col}
 }
 
 // Next moves the position to the next character.
 func (c *Code) Next() {
-	c.advanceC(c.Curr())
+	if c.toks != nil {
+		if c.More() {
+			c.pos++
+			c.tokpos()
+		}
+		return
+	}
+	c.move(c.curr())
 }
 
 func (c *Code) Curr() rune {
-	r, _ := utf8.DecodeRuneInString(c.Tail())
+	if c.toks != nil {
+		if c.More() {
+			r, _ := utf8.DecodeRuneInString(c.toks[c.pos].Text)
+			return r
+		}
+		return utf8.RuneError
+	}
+	r, _ := c.curr()
 	return r
 }
 
+// curr returns the current character and its
+// width in bytes. An invalid UTF-8 byte is one
+// utf8.RuneError character of width one.
+func (c *Code) curr() (rune, int) {
+	if c.bin {
+		if c.More() {
+			return rune(c.byteAt(c.pos)), 1
+		}
+		return utf8.RuneError, 0
+	}
+	c.fill(utf8.UTFMax)
+	if c.win != nil {
+		return utf8.DecodeRune(c.win[c.pos-c.off:])
+	}
+	return utf8.DecodeRuneInString(c.src[c.pos:])
+}
+
 // Tail returns the content from the
-// current position to the end.
+// current position to the end. When
+// reading from a reader it returns a
+// copy of the buffered content only.
 func (c *Code) Tail() string {
-	return c.src[c.pos:]
+	if c.toks != nil {
+		if c.More() {
+			return c.src[c.toks[c.pos].Pos:]
+		}
+		return ""
+	}
+	return c.text(c.pos, c.pos+c.buffered())
 }
 
 // More tells if there are more characters to scan.
 func (c *Code) More() bool {
-	return c.pos < len(c.src)
+	if c.err != nil {
+		return false
+	}
+	if c.toks != nil {
+		return c.pos < len(c.toks)
+	}
+	c.fill(1)
+	return c.buffered() > 0
+}
+
+// fill reads from the reader until there are
+// at least n bytes buffered after the current
+// position or the reader ends. The input
+// before the oldest held position is dropped
+// when the window is full, and the window
+// doubles when it is still half full after.
+func (c *Code) fill(n int) {
+	for c.rd != nil && c.buffered() < n {
+		if cap(c.win)-len(c.win) < readSize {
+			low := c.pos
+			for _, p := range c.pins {
+				if p < low {
+					low = p
+				}
+			}
+			k := copy(c.win, c.win[low-c.off:])
+			c.win = c.win[:k]
+			c.off = low
+			if k > cap(c.win)/2 || cap(c.win)-k < readSize {
+				w := make([]byte, k, 2*cap(c.win)+readSize)
+				copy(w, c.win)
+				c.win = w
+			}
+		}
+		k, err := c.rd.Read(c.win[len(c.win) : len(c.win)+readSize])
+		c.win = c.win[:len(c.win)+k]
+		if err != nil {
+			if err != io.EOF {
+				c.err = err
+			}
+			c.rd = nil
+		}
+	}
 }
 
+// buffered returns the number of bytes
+// buffered after the current position.
+func (c *Code) buffered() int {
+	if c.win != nil {
+		return len(c.win) - (c.pos - c.off)
+	}
+	return len(c.src) - c.pos
+}
+
+// text returns the input between the positions
+// i and j. When reading from a reader it is a
+// copy, so it does not keep the window alive.
+func (c *Code) text(i, j int) string {
+	if c.win != nil {
+		return string(c.win[i-c.off : j-c.off])
+	}
+	return c.src[i:j]
+}
+
+// byteAt returns the byte at the position i.
+func (c *Code) byteAt(i int) byte {
+	if c.win != nil {
+		return c.win[i-c.off]
+	}
+	return c.src[i]
+}
+
+// after returns the mark after scanning s from m.
+func (c *Code) after(m Mark, s string) Mark {
+	if c.bin {
+		m.col += len(s)
+	} else {
+		for _, r := range s {
+			m.col++
+			if r == '\n' {
+				m.row++
+				m.col = 1
+			}
+		}
+	}
+	m.pos += len(s)
+	return m
+}
+
+// runeReader reads the code from a position on
+// without moving the current position.
+type runeReader struct {
+	c   *Code
+	pos int
+}
+
+func (r *runeReader) ReadRune() (rune, int, error) {
+	c := r.c
+	c.fill(r.pos - c.pos + utf8.UTFMax)
+	if r.pos-c.pos >= c.buffered() {
+		return 0, 0, io.EOF
+	}
+	if c.bin {
+		r.pos++
+		return rune(c.byteAt(r.pos - 1)), 1, nil
+	}
+	ru, n := utf8.DecodeRune(c.win[r.pos-c.off:])
+	r.pos += n
+	return ru, n, nil
+}
+
+// hold marks the current position and keeps
+// the input from there buffered until release.
+func (c *Code) hold() Mark {
+	c.pins = append(c.pins, c.pos)
+	return c.Mark()
+}
+
+// release releases the last hold.
+func (c *Code) release() {
+	c.pins = c.pins[:len(c.pins)-1]
+}
+
+// advance moves the position past s, which
+// must be the input at the current position.
 func (c *Code) advance(s string) {
-	for _, r := range s {
-		c.advanceC(r)
+	if c.toks != nil {
+		c.Next()
+		return
+	}
+	for len(s) > 0 {
+		r, n := rune(s[0]), 1
+		if !c.bin {
+			r, n = utf8.DecodeRuneInString(s)
+		}
+		c.move(r, n)
+		s = s[n:]
 	}
 }
 
-func (c *Code) advanceC(r rune) {
+// move moves the position past the
+// character r of n bytes.
+func (c *Code) move(r rune, n int) {
 	if c.More() {
 		c.rowcol(r)
-		c.pos += utf8.RuneLen(r)
+		c.pos += n
 	}
 }
 
 func (c *Code) rowcol(r rune) {
 	c.col++
-	if r == '\n' {
+	if r == '\n' && !c.bin {
 		c.row++
 		c.col = 1
 	}
@@ -91,10 +323,44 @@ func (c *Code) rowcol(r rune) {
 
 type Code struct {
 	src string // Source code.
+	off int    // Offset of win when reading from a reader.
 	pos int    // Position/Index/Offset/Cursor.
 	row int    // Current line.
 	col int    // Current column.
 	ast *AST   // Used to build an AST.
+
+	bin bool // Scans bytes instead of characters.
+	cst bool // Concrete syntax tree mode.
+	tx  bool // Transactional mode.
+
+	ind *indent // Indentation stack.
+
+	toks []Token // Tokens of a token code.
+
+	rd   io.Reader // Reader of the source code.
+	win  []byte    // Buffered input when reading from a reader.
+	pins []int     // Held positions.
+	err  error     // Error that stops the scan.
+
+	ctx      context.Context // Stops the scan when done.
+	steps    int             // Steps taken.
+	backs    int             // Backtracks taken.
+	maxSteps int             // Maximum steps, if > 0.
+	maxBacks int             // Maximum backtracks, if > 0.
+	depth    int             // Nesting level.
+	maxDepth int             // Maximum nesting level, if > 0.
+
+	tracer *tracer  // Used by Trace.
+	prof   *Profile // Used by Profile.
+
+	txs    int      // Open transactions.
+	events []func() // Buffered events.
+
+	fail  failure // Furthest failure, used to report errors.
+	quiet int     // When > 0 failures are not recorded.
+
+	memo  map[memoKey]*memoEntry // Used by Memo.
+	seeds map[memoKey]*memoEntry // Used by LeftRecursive.
 }
 
 // Mark represents a mark in the code.
@@ -102,6 +368,22 @@ type Mark struct {
 	pos int
 	row int
 	col int
+	ind *indent
+}
+
+// Pos returns the byte offset of the mark.
+func (m Mark) Pos() int {
+	return m.pos
+}
+
+// Row returns the line of the mark.
+func (m Mark) Row() int {
+	return m.row
+}
+
+// Col returns the column of the mark.
+func (m Mark) Col() int {
+	return m.col
 }
 
 // Token represents a token of the code.
@@ -110,4 +392,5 @@ type Token struct {
 	Pos  int
 	Row  int
 	Col  int
+	Kind string // Kind given by a Lexer.
 }
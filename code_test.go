@@ -1,7 +1,9 @@
 package calm
 
 import (
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -67,3 +69,56 @@ func TestMarkBackToken(t *testing.T) {
 
 	assert.Equal(t, "a", c.Token(ini, end).Text)
 }
+
+func TestNewReader(t *testing.T) {
+
+	src := strings.Repeat("ab ab\n", 3000)
+
+	c := NewReader(iotest.HalfReader(strings.NewReader(src)))
+
+	var words []Token
+	word := Or(AND(S("ab"), S("x")), S("ab")).On(Emits(&words))
+	root := Or(word, Next()).OneToMany()
+
+	ok := root.Run(c)
+
+	assert.True(t, ok)
+	assert.Len(t, words, 6000)
+	assert.Equal(t, Token{Text: "ab", Pos: 0, Row: 1, Col: 1}, words[0])
+	assert.Equal(t, Token{Text: "ab", Pos: 17997, Row: 3000, Col: 4}, words[5999])
+	assert.LessOrEqual(t, cap(c.win), 3*readSize)
+}
+
+func TestNewReader_Undo(t *testing.T) {
+
+	src := strings.Repeat("a", 3*readSize) + "b"
+
+	c := NewReader(strings.NewReader(src))
+
+	var tk string
+	ok := Or(And(S("a").OneToMany(), S("c")).Undo(), And(S("a").OneToMany(), S("b"))).On(Grab(&tk)).Run(c)
+
+	assert.True(t, ok)
+	assert.Equal(t, src, tk)
+}
+
+func TestNewReader_Failure(t *testing.T) {
+
+	src := strings.Repeat("a", 4*readSize) + "b" + strings.Repeat("a", 4*readSize)
+
+	c := NewReader(strings.NewReader(src))
+
+	err := And(S("a").OneToMany(), S("c")).RunErr(c)
+
+	assert.EqualError(t, err, `1:16385: unexpected "baaaaaaaaaaaaaaa", expected S("a") or S("c")`)
+	assert.Equal(t, "baaaaaaaaaaaaaaa", c.fail.near)
+}
+
+func TestNewReader_Error(t *testing.T) {
+
+	c := NewReader(iotest.TimeoutReader(strings.NewReader(strings.Repeat("a", 2*readSize))))
+
+	err := S("a").OneToMany().RunErr(c)
+
+	assert.Equal(t, iotest.ErrTimeout, err)
+}
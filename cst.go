@@ -0,0 +1,106 @@
+package calm
+
+import (
+	"sort"
+	"strings"
+)
+
+// CST turns on the concrete syntax tree mode. In this
+// mode Tree attaches the input that no node consumed,
+// like spaces and comments, to the nodes around it as
+// Leading and Trailing trivia, so Source gives the
+// input back byte for byte.
+func (c *Code) CST() *Code {
+	c.cst = true
+	return c
+}
+
+// Source returns the source code of the node. In CST
+// mode it is the input the node was parsed from,
+// with the changes made to the tree.
+func (a *AST) Source() string {
+	var b strings.Builder
+	a.source(&b)
+	return b.String()
+}
+
+func (a *AST) source(b *strings.Builder) {
+	b.WriteString(a.Leading)
+	for _, p := range a.pieces() {
+		if p.node == nil {
+			b.WriteString(a.Name.Text)
+		} else {
+			p.node.source(b)
+		}
+	}
+	b.WriteString(a.Trailing)
+}
+
+// trivia attaches to the nodes the input
+// that is inside their spans but that
+// no node consumed. text returns the
+// input between two positions.
+func (a *AST) trivia(text func(i, j int) string) {
+	if a.Span.Start.row == 0 {
+		return
+	}
+	pos := a.Span.Start.pos
+	var prev *AST // Previous child.
+	for _, p := range a.pieces() {
+		if p.ini > pos {
+			gap := text(pos, p.ini)
+			switch {
+			case p.node != nil:
+				p.node.Leading = gap + p.node.Leading
+			case prev != nil:
+				prev.Trailing += gap
+			default:
+				a.Leading += gap
+			}
+		}
+		if p.end > pos {
+			pos = p.end
+		}
+		prev = p.node
+	}
+	if end := a.Span.End.pos; end > pos {
+		if prev != nil {
+			prev.Trailing += text(pos, end)
+		} else {
+			a.Trailing = text(pos, end) + a.Trailing
+		}
+	}
+	for _, b := range a.Args {
+		b.trivia(text)
+	}
+}
+
+// piece is the name of a node (node is
+// nil) or one of its children.
+type piece struct {
+	node     *AST
+	ini, end int
+}
+
+// pieces returns the name and the children
+// of the node in the order of the input.
+// The name is left out when it is empty or
+// when it overlaps a child.
+func (a *AST) pieces() []piece {
+	ps := make([]piece, 0, len(a.Args)+1)
+	name := a.Name.Text != ""
+	ini, end := a.Name.Pos, a.Name.Pos+len(a.Name.Text)
+	for _, b := range a.Args {
+		ps = append(ps, piece{node: b, ini: b.Span.Start.pos, end: b.Span.End.pos})
+		if b.Span.Start.row > 0 && ini < b.Span.End.pos && b.Span.Start.pos < end {
+			name = false
+		}
+	}
+	if name {
+		ps = append([]piece{{ini: ini, end: end}}, ps...)
+	}
+	sort.SliceStable(ps, func(i, j int) bool {
+		return ps[i].ini < ps[j].ini
+	})
+	return ps
+}
@@ -0,0 +1,100 @@
+package calm
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCST(t *testing.T) {
+
+	tt := []string{
+		"",
+		"package main",
+		"\n// Comment.\npackage  main\n\nimport \"fmt\"\n",
+		"package main\n\nfunc main() {\n\tfmt.Println(\"Hello, 世界\")\n\t// Bye.\n\tfmt.Print(\"Bye\")\n}\n",
+	}
+
+	for _, src := range tt {
+
+		var ast AST
+		ok := cstTestParser().Tree(&ast).Run(New(src).CST())
+
+		assert.True(t, ok, src)
+		assert.Equal(t, src, ast.Source(), src)
+	}
+}
+
+func TestCST_Trivia(t *testing.T) {
+
+	src := " package main // main\n"
+
+	var ast AST
+	ok := cstTestParser().Tree(&ast).Run(New(src).CST())
+
+	pkg := ast.MustQuery("Pkg")
+
+	assert.True(t, ok)
+	assert.Equal(t, " ", pkg.Leading)
+	assert.Equal(t, " // main\n", pkg.Trailing)
+	assert.Equal(t, " ", pkg.MustQuery("Name").Leading)
+	assert.Equal(t, "", pkg.MustQuery("Name").Trailing)
+	assert.Equal(t, "", ast.Leading+ast.Trailing)
+}
+
+func TestCST_Rewrite(t *testing.T) {
+
+	src := "package main\n\nfunc main() {\n\tfmt.Println(\"a\")\n}\n"
+	exp := "package main\n\nfunc main() {\n\tfmt.Print(\"a\")\n}\n"
+
+	var ast AST
+	cstTestParser().Tree(&ast).Run(New(src).CST())
+	ast.MustQuery("Call > Name[=Println]").Name.Text = "Print"
+
+	assert.Equal(t, exp, ast.Source())
+}
+
+func TestCST_Off(t *testing.T) {
+
+	src := "package  main\n"
+
+	var ast AST
+	cstTestParser().Tree(&ast).Run(New(src))
+
+	assert.Equal(t, "packagemain", ast.Source())
+}
+
+func cstTestParser() MatcherFunc {
+	ws := SOr(" \t").OneToMany()
+	wz := F(unicode.IsSpace).ZeroToMany()
+	name := F(unicode.IsLetter).OneToMany()
+	strg := String(`"`).Leaf("Str")
+	comment := And(S("//"), Until(Eq("\n")))
+
+	pkgDef := S("package").Leaf("Pkg").Child(ws, name.Leaf("Name"))
+	impDef := S("import").Leaf("Imp").Child(ws, strg)
+	fnCall := And(name.Leaf("Pkg"), S("."), name.Leaf("Name"), S("("), strg, S(")")).Group("Call")
+	fnBody := Or(F(unicode.IsSpace), comment, fnCall).ZeroToMany().Group("Body")
+	fnDefn := S("func").Leaf("Fun").Child(ws, name.Leaf("Name"), S("()"), wz, S("{"), fnBody, S("}"))
+
+	return Or(F(unicode.IsSpace), comment, pkgDef, impDef, fnDefn).ZeroToMany()
+}
+
+func TestCST_JSON(t *testing.T) {
+
+	src := "package main // main\n"
+
+	var ast AST
+	cstTestParser().Tree(&ast).Run(New(src).CST())
+
+	for _, format := range []string{"json-full", "json-full-inline"} {
+
+		got, err := ParseAST(strings.NewReader(ast.Print(format)))
+
+		assert.NoError(t, err, format)
+		assert.Equal(t, &ast, got, format)
+		assert.Equal(t, src, got.Source(), format)
+	}
+}
@@ -0,0 +1,202 @@
+package calm
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// RunErr runs the current matcher like Run, but returns a
+// *ParseError describing the furthest failure when it fails.
+// Only the failures of this run are reported.
+func (m MatcherFunc) RunErr(c *Code) error {
+	c.fail = c.failure()
+	ok := m(c)
+	if c.err != nil {
+		return c.err
+	}
+	if ok {
+		return nil
+	}
+	return c.parseError()
+}
+
+// Parse runs a matcher against the code. See RunErr.
+func (c *Code) Parse(m MatcherFunc) error {
+	return m.RunErr(c)
+}
+
+// Named gives the current matcher a readable name.
+// When it fails without getting past its first
+// character, errors report the name instead of
+// what each inner matcher expected. Otherwise its
+// inner failures are reported as they are, like
+// the digit that Number expects after "12" in
+// "12x", even when it succeeds.
+func (m MatcherFunc) Named(name string) MatcherFunc {
+	return func(c *Code) bool {
+		ini := c.Mark()
+		if c.tracer != nil {
+			c.hold()
+			c.tracer.enter(c, name, ini)
+		}
+		if c.prof != nil {
+			c.prof.enter(name)
+		}
+		out := c.fail
+		c.fail = c.failure()
+		ok := m(c)
+		if c.prof != nil {
+			c.prof.exit(name, ok, c.pos-ini.pos)
+		}
+		if c.tracer != nil {
+			c.tracer.exit(c, name, ini, ok)
+			c.release()
+		}
+		in := c.fail
+		c.fail = out
+		if !ok && in.at.pos == ini.pos {
+			in.exp = []string{name}
+		}
+		c.merge(in)
+		return ok
+	}
+}
+
+// quiet runs the current matcher without recording
+// its failures, for matchers that are not worth
+// reporting, like optional spaces.
+func (m MatcherFunc) quiet() MatcherFunc {
+	return func(c *Code) bool {
+		c.quiet++
+		ok := m(c)
+		c.quiet--
+		return ok
+	}
+}
+
+// ParseError describes where and why a parse failed.
+type ParseError struct {
+	Mark     Mark     // Furthest position reached.
+	Row      int      // Furthest line reached.
+	Col      int      // Furthest column reached.
+	Snippet  string   // Input at the furthest position.
+	Expected []string // What was expected at the furthest position.
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d:%d: ", e.Row, e.Col)
+	if e.Snippet == "" {
+		b.WriteString("unexpected end of input")
+	} else {
+		fmt.Fprintf(&b, "unexpected %q", e.Snippet)
+	}
+	if len(e.Expected) > 0 {
+		b.WriteString(", expected ")
+		b.WriteString(strings.Join(e.Expected, " or "))
+	}
+	return b.String()
+}
+
+// expect records that something was expected
+// at the current position but was not found.
+// Only the furthest position is kept.
+func (c *Code) expect(s string) {
+	if c.quiet > 0 {
+		return
+	}
+	switch {
+	case c.pos > c.fail.at.pos:
+		c.fail = c.failure()
+		c.fail.exp = []string{s}
+	case c.pos == c.fail.at.pos:
+		c.fail.add(s)
+	}
+}
+
+// reach records that the scan got to the current
+// position, so that errors point at least there
+// even when nothing recorded what was expected.
+func (c *Code) reach() {
+	if c.quiet == 0 && c.pos > c.fail.at.pos {
+		c.fail = c.failure()
+	}
+}
+
+// merge merges a failure into the furthest failure.
+func (c *Code) merge(f failure) {
+	if c.quiet > 0 {
+		return
+	}
+	switch {
+	case f.at.pos > c.fail.at.pos:
+		c.fail = failure{at: f.at, exp: append([]string(nil), f.exp...), near: f.near}
+	case f.at.pos == c.fail.at.pos:
+		for _, e := range f.exp {
+			c.fail.add(e)
+		}
+	}
+}
+
+// parseError builds a *ParseError from the furthest failure.
+func (c *Code) parseError() *ParseError {
+	at := c.fail.at
+	return &ParseError{
+		Mark:     at,
+		Row:      at.row,
+		Col:      at.col,
+		Snippet:  snippet(c.fail.near),
+		Expected: append([]string(nil), c.fail.exp...),
+	}
+}
+
+// failure returns an empty failure at the
+// current position. Only the snippet of the
+// input is kept, not the whole input window.
+func (c *Code) failure() failure {
+	if c.toks != nil {
+		return failure{at: c.Mark(), near: snippet(c.Tail())}
+	}
+	c.fill(snippetLen * utf8.UTFMax)
+	n := c.buffered()
+	if n > snippetLen*utf8.UTFMax {
+		n = snippetLen * utf8.UTFMax
+	}
+	return failure{at: c.Mark(), near: snippet(c.text(c.pos, c.pos+n))}
+}
+
+// snippet returns the rest of the line,
+// truncated to a few characters.
+func snippet(s string) string {
+	if i := strings.IndexByte(s, '\n'); i > 0 {
+		s = s[:i]
+	} else if i == 0 {
+		s = s[:1]
+	}
+	for n := 1; n < len(s); n++ {
+		if n >= snippetLen && utf8.RuneStart(s[n]) {
+			return s[:n]
+		}
+	}
+	return s
+}
+
+const snippetLen = 16
+
+// failure holds the furthest position where a
+// matcher failed and what was expected there.
+type failure struct {
+	at   Mark
+	exp  []string
+	near string // Snippet of the input at the failure.
+}
+
+func (f *failure) add(s string) {
+	for _, e := range f.exp {
+		if e == s {
+			return
+		}
+	}
+	f.exp = append(f.exp, s)
+}
@@ -0,0 +1,119 @@
+package calm
+
+import (
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunErr(t *testing.T) {
+
+	tt := []struct {
+		in  string
+		mf  MatcherFunc
+		err string
+	}{
+		{"ab", And(S("a"), S("b")), ""},
+		{"ax", And(S("a"), S("b")), `1:2: unexpected "x", expected S("b")`},
+		{"a", And(S("a"), S("b")), `1:2: unexpected end of input, expected S("b")`},
+		{"ac", And(S("a"), Or(S("b"), SOr("12"))), `1:2: unexpected "c", expected S("b") or SOr("12")`},
+		{"x", F(unicode.IsDigit), `1:1: unexpected "x", expected F(unicode.IsDigit)`},
+		{"x", R("[0-9]"), `1:1: unexpected "x", expected R("[0-9]")`},
+		{"x", Eq("y"), `1:1: unexpected "x", expected Eq("y")`},
+		{"x", EqF(unicode.IsDigit), `1:1: unexpected "x", expected EqF(unicode.IsDigit)`},
+		{"a\nbc", And(S("a\nb"), S("d")), `2:2: unexpected "c", expected S("d")`},
+		{"a\n", And(S("a"), S("b")), `1:2: unexpected "\n", expected S("b")`},
+		{"abcdefghijklmnopqrstuvwxyz", S("b"), `1:1: unexpected "abcdefghijklmnop", expected S("b")`},
+		// The furthest failure wins.
+		{"abx", Or(AND(S("a"), S("b"), S("c")), S("z")), `1:3: unexpected "x", expected S("c")`},
+		// Failures inside Not are not reported.
+		{"ab", And(S("x").Not(), S("b")), `1:1: unexpected "ab", expected S("b")`},
+		{"x", False(), `1:1: unexpected "x"`},
+		// And reports where its failing matcher starts.
+		{"abc", And(S("ab"), False()), `1:3: unexpected "c"`},
+	}
+
+	for _, tc := range tt {
+
+		c := New(tc.in)
+
+		err := tc.mf.RunErr(c)
+
+		if tc.err == "" {
+			assert.NoError(t, err, tc.in)
+		} else {
+			assert.EqualError(t, err, tc.err, tc.in)
+		}
+	}
+}
+
+func TestRunErr_Reuse(t *testing.T) {
+
+	c := New("ab")
+	ini := c.Mark()
+
+	err := And(S("a"), S("x")).RunErr(c)
+
+	assert.EqualError(t, err, `1:2: unexpected "b", expected S("x")`)
+
+	c.Back(ini)
+	err = S("b").RunErr(c)
+
+	assert.EqualError(t, err, `1:1: unexpected "ab", expected S("b")`)
+}
+
+func TestParse(t *testing.T) {
+
+	c := New(`{ "a": 1, "b": [2, }`)
+
+	err := c.Parse(Json())
+
+	perr, ok := err.(*ParseError)
+	assert.True(t, ok)
+	assert.Equal(t, 1, perr.Row)
+	assert.Equal(t, 20, perr.Col)
+	assert.Equal(t, "}", perr.Snippet)
+	assert.Equal(t, []string{"value"}, perr.Expected)
+}
+
+func TestNamed(t *testing.T) {
+
+	digits := F(unicode.IsDigit).OneToMany()
+	pair := And(digits, S(":"), digits).Named("pair")
+
+	tt := []struct {
+		in  string
+		mf  MatcherFunc
+		err string
+	}{
+		{"1:2", pair, ""},
+		// Fails at its start, so the name is reported.
+		{"x", pair, `1:1: unexpected "x", expected pair`},
+		// Fails after its start, so the details are reported.
+		{"1x", pair, `1:2: unexpected "x", expected F(unicode.IsDigit) or S(":")`},
+		{"1:x", pair, `1:3: unexpected "x", expected F(unicode.IsDigit)`},
+		// Inner failures of a success are reported too.
+		{"1:2x", And(pair, S(",")), `1:4: unexpected "x", expected F(unicode.IsDigit) or S(",")`},
+		{"12x", And(Number(), S(",")), `1:3: unexpected "x", expected F(unicode.IsDigit) or S(".") or SOr("Ee") or S(",")`},
+		{"x", Or(pair, S("-").Named("dash")), `1:1: unexpected "x", expected pair or dash`},
+		{"[1,", Json(), `1:4: unexpected end of input, expected value`},
+		{`{"a": 1, "b": }`, Json(), `1:15: unexpected "}", expected value`},
+		{"[1, 2,]", Json(), `1:7: unexpected "]", expected value`},
+		{`{ "a": 1, }`, Json(), `1:11: unexpected "}", expected string`},
+		{"<a<b>", Tag("<", ">"), `1:6: unexpected end of input, expected tag or S(">")`},
+	}
+
+	for _, tc := range tt {
+
+		c := New(tc.in)
+
+		err := tc.mf.RunErr(c)
+
+		if tc.err == "" {
+			assert.NoError(t, err, tc.in)
+		} else {
+			assert.EqualError(t, err, tc.err, tc.in)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package calm
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Generate writes the Go source of a parser for a
+// grammar (see Grammar) to w. The parser does not
+// depend on this package and it works like the
+// matchers built by Grammar, including the errors.
+// Its Parse function parses with the first rule.
+// References must be defined in the grammar,
+// rules can not be left recursive and an item
+// marked with '^' must be between other items.
+func Generate(w io.Writer, pkg, src string) error {
+	rules, err := parseGrammar(src)
+	if err != nil {
+		return err
+	}
+	g := &generator{rules: map[string]bool{}}
+	left := leftRecursive(rules)
+	for _, r := range rules {
+		name := r.Left().Name
+		if left[name.Text] {
+			return fmt.Errorf("left recursive rule %s at %d:%d", name.Text, name.Row, name.Col)
+		}
+		g.rules[name.Text] = true
+	}
+	fmt.Fprintf(&g.out, "// Code generated by calm gen. DO NOT EDIT.\n\npackage %s\n", pkg)
+	fmt.Fprintf(&g.out, genRuntime, "r"+rules[0].Left().Name.Text)
+	for _, r := range rules {
+		if err := g.rule(r.Left().Name.Text, r.Right()); err != nil {
+			return err
+		}
+	}
+	b, err := format.Source(g.out.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+type generator struct {
+	out   bytes.Buffer
+	rules map[string]bool
+	n     int
+}
+
+// rule writes the method of a rule.
+func (g *generator) rule(name string, body *AST) error {
+	e, err := g.expr(body)
+	if err != nil {
+		return err
+	}
+	node := unicode.IsUpper([]rune(name)[0])
+	g.printf("\nfunc (p *parser) r%s() bool {\n", name)
+	g.printf("ini, out := p.mark(), p.fail\n")
+	g.printf("p.fail = failure{at: ini}\n")
+	if node {
+		g.printf("parent, node := p.ast, &AST{Type: %q}\n", name)
+		g.printf("p.ast = node\n")
+	}
+	g.printf("ok := p.%s()\n", e)
+	if node {
+		g.printf("p.ast = parent\n")
+		g.printf("if ok {\n")
+		g.printf("if len(node.Args) == 0 {\nnode.Name = p.token(ini)\n}\n")
+		g.printf("parent.Args = append(parent.Args, node)\n")
+		g.printf("}\n")
+	}
+	g.printf("in := p.fail\n")
+	g.printf("p.fail = out\n")
+	g.printf("if !ok && in.at.pos == ini.pos {\nin.exp = []string{%q}\n}\np.merge(in)\n", name)
+	g.printf("return ok\n}\n")
+	return nil
+}
+
+// expr writes the method of an expression
+// and returns its name.
+func (g *generator) expr(n *AST) (string, error) {
+	switch n.Type {
+	case "Choice", "Seq":
+		es := make([]string, 0, len(n.Args))
+		for _, a := range n.Args {
+			e, err := g.expr(a)
+			if err != nil {
+				return "", err
+			}
+			es = append(es, "p."+e+"()")
+		}
+		root, _ := rootIndex(n)
+		if root == 0 || root > 0 && root == len(es)-1 {
+			m := n.Args[root].Args[len(n.Args[root].Args)-1].Name
+			return "", fmt.Errorf("root on the first or last item of a sequence at %d:%d", m.Row, m.Col)
+		}
+		if len(es) == 1 && root < 0 {
+			return es[0][2 : len(es[0])-2], nil
+		}
+		name := g.name()
+		g.printf("\nfunc (p *parser) %s() bool {\n", name)
+		switch {
+		case len(es) == 0:
+			g.printf("return true\n")
+		case n.Type == "Choice":
+			g.printf("return %s\n", strings.Join(es, " || "))
+		default:
+			g.printf("m := p.mark()\n")
+			g.printf("if %s {\n", strings.Join(es, " && "))
+			if root >= 0 {
+				g.printf("p.root()\n")
+			}
+			g.printf("return true\n}\n")
+			g.printf("p.back(m)\nreturn false\n")
+		}
+		g.printf("}\n")
+		return name, nil
+	case "Item":
+		var pred, rep, e string
+		for _, a := range n.Args {
+			switch a.Type {
+			case "Pred":
+				pred = a.Name.Text
+			case "Rep":
+				rep = a.Name.Text
+			case "Root":
+			default:
+				var err error
+				if e, err = g.expr(a); err != nil {
+					return "", err
+				}
+			}
+		}
+		if rep != "" {
+			name := g.name()
+			g.printf("\nfunc (p *parser) %s() bool {\n", name)
+			switch rep {
+			case "?":
+				g.printf("p.%s()\n", e)
+			case "+":
+				g.printf("if !p.%s() {\nreturn false\n}\n", e)
+				fallthrough
+			case "*":
+				g.printf("for pos := p.pos; p.%s(); pos = p.pos {\nif p.pos == pos {\np.stuck()\n}\n}\n", e)
+			}
+			g.printf("return true\n}\n")
+			e = name
+		}
+		if pred != "" {
+			name := g.name()
+			g.printf("\nfunc (p *parser) %s() bool {\n", name)
+			g.printf("m := p.mark()\n")
+			if pred == "!" {
+				g.printf("p.quiet++\nok := !p.%s()\np.quiet--\n", e)
+			} else {
+				g.printf("ok := p.%s()\n", e)
+			}
+			g.printf("p.back(m)\nreturn ok\n}\n")
+			e = name
+		}
+		return e, nil
+	case "Many":
+		e, err := g.expr(n.Args[0])
+		if err != nil {
+			return "", err
+		}
+		name := g.name()
+		g.printf("\nfunc (p *parser) %s() bool {\n", name)
+		g.printf("for pos := p.pos; p.%s(); pos = p.pos {\nif p.pos == pos {\np.stuck()\n}\n}\n", e)
+		g.printf("return true\n}\n")
+		return name, nil
+	case "Ref":
+		if !g.rules[n.Name.Text] {
+			return "", fmt.Errorf("undefined rule %s at %d:%d", n.Name.Text, n.Name.Row, n.Name.Col)
+		}
+		return "r" + n.Name.Text, nil
+	case "Lit":
+		lit := unquote(n.Name.Text)
+		name := g.name()
+		g.printf("\nfunc (p *parser) %s() bool {\n", name)
+		g.printf("return p.lit(%s, %s)\n}\n", strconv.Quote(lit), strconv.Quote("S("+strconv.Quote(lit)+")"))
+		return name, nil
+	case "Class":
+		set, neg := parseClass(n.Name.Text)
+		cond := "false"
+		for i, cr := range set {
+			if i == 0 {
+				cond = ""
+			} else {
+				cond += " || "
+			}
+			if cr.lo == cr.hi {
+				cond += "r == " + strconv.QuoteRune(cr.lo)
+			} else {
+				cond += strconv.QuoteRune(cr.lo) + " <= r && r <= " + strconv.QuoteRune(cr.hi)
+			}
+		}
+		if neg {
+			cond = "!(" + cond + ")"
+		}
+		name := g.name()
+		g.printf("\nfunc (p *parser) %s() bool {\n", name)
+		g.printf("if r, n := p.curr(); n > 0 && (%s) {\np.next(n)\nreturn true\n}\n", cond)
+		g.printf("p.expect(%s)\nreturn false\n}\n", strconv.Quote(n.Name.Text))
+		return name, nil
+	case "Any":
+		name := g.name()
+		g.printf("\nfunc (p *parser) %s() bool {\n", name)
+		g.printf("if _, n := p.curr(); n > 0 {\np.next(n)\nreturn true\n}\nreturn false\n}\n")
+		return name, nil
+	}
+	panic("unknown grammar node " + n.Type)
+}
+
+func (g *generator) name() string {
+	g.n++
+	return "e" + strconv.Itoa(g.n)
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.out, format, args...)
+}
+
+// genRuntime is the runtime of the generated parsers.
+const genRuntime = `
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Parse parses the source code.
+func Parse(src string) (*AST, error) {
+	p := &parser{src: src, row: 1, col: 1, ast: &AST{Type: "Root"}}
+	p.fail.at = p.mark()
+	ok, err := p.run(p.%s)
+	if err != nil {
+		return p.ast, err
+	}
+	if ok {
+		return p.ast, nil
+	}
+	return p.ast, p.fail.err(src)
+}
+
+type AST struct {
+	Type string
+	Name Token
+	Args []*AST
+}
+
+type Token struct {
+	Text string
+	Pos  int
+	Row  int
+	Col  int
+}
+
+// ParseError describes where and why a parse failed.
+type ParseError struct {
+	Row      int
+	Col      int
+	Snippet  string
+	Expected []string
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%%d:%%d: ", e.Row, e.Col)
+	if e.Snippet == "" {
+		b.WriteString("unexpected end of input")
+	} else {
+		fmt.Fprintf(&b, "unexpected %%q", e.Snippet)
+	}
+	if len(e.Expected) > 0 {
+		b.WriteString(", expected ")
+		b.WriteString(strings.Join(e.Expected, " or "))
+	}
+	return b.String()
+}
+
+type parser struct {
+	src   string
+	pos   int
+	row   int
+	col   int
+	ast   *AST
+	fail  failure
+	quiet int
+}
+
+type mark struct {
+	pos  int
+	row  int
+	col  int
+	args int
+}
+
+func (p *parser) mark() mark {
+	return mark{pos: p.pos, row: p.row, col: p.col, args: len(p.ast.Args)}
+}
+
+func (p *parser) back(m mark) {
+	p.pos, p.row, p.col = m.pos, m.row, m.col
+	p.ast.Args = p.ast.Args[:m.args]
+}
+
+func (p *parser) token(ini mark) Token {
+	return Token{Text: p.src[ini.pos:p.pos], Pos: ini.pos, Row: ini.row, Col: ini.col}
+}
+
+func (p *parser) curr() (rune, int) {
+	return utf8.DecodeRuneInString(p.src[p.pos:])
+}
+
+func (p *parser) next(n int) {
+	p.col++
+	if p.src[p.pos] == '\n' {
+		p.row++
+		p.col = 1
+	}
+	p.pos += n
+}
+
+func (p *parser) lit(s, exp string) bool {
+	if !strings.HasPrefix(p.src[p.pos:], s) {
+		p.expect(exp)
+		return false
+	}
+	for p.pos < len(p.src) && len(s) > 0 {
+		_, n := p.curr()
+		p.next(n)
+		s = s[n:]
+	}
+	return true
+}
+
+// errLoop stops a parse in a repetition
+// that matches without moving the position.
+type errLoop struct {
+	row int
+	col int
+}
+
+func (p *parser) stuck() {
+	panic(errLoop{p.row, p.col})
+}
+
+// run runs a rule and returns an error
+// when a repetition gets stuck in it.
+func (p *parser) run(rule func() bool) (ok bool, err error) {
+	root := p.ast
+	defer func() {
+		if r := recover(); r != nil {
+			l, is := r.(errLoop)
+			if !is {
+				panic(r)
+			}
+			p.ast, err = root, fmt.Errorf("infinite loop at %%d:%%d", l.row, l.col)
+		}
+	}()
+	return rule(), nil
+}
+
+// root makes the middle node of the
+// last three nodes their parent.
+func (p *parser) root() {
+	if args := p.ast.Args; len(args) >= 3 {
+		a, o, b := args[len(args)-3], args[len(args)-2], args[len(args)-1]
+		o.Args = append(o.Args, a, b)
+		p.ast.Args = append(args[:len(args)-3], o)
+	}
+}
+
+type failure struct {
+	at  mark
+	exp []string
+}
+
+func (p *parser) expect(s string) {
+	p.merge(failure{at: p.mark(), exp: []string{s}})
+}
+
+func (p *parser) merge(f failure) {
+	if p.quiet > 0 {
+		return
+	}
+	switch {
+	case f.at.pos > p.fail.at.pos:
+		p.fail = failure{at: f.at, exp: append([]string(nil), f.exp...)}
+	case f.at.pos == p.fail.at.pos:
+	next:
+		for _, s := range f.exp {
+			for _, e := range p.fail.exp {
+				if e == s {
+					continue next
+				}
+			}
+			p.fail.exp = append(p.fail.exp, s)
+		}
+	}
+}
+
+func (f failure) err(src string) *ParseError {
+	s := src[f.at.pos:]
+	if i := strings.IndexByte(s, '\n'); i > 0 {
+		s = s[:i]
+	} else if i == 0 {
+		s = s[:1]
+	}
+	for n := 1; n < len(s); n++ {
+		if n >= 16 && utf8.RuneStart(s[n]) {
+			s = s[:n]
+			break
+		}
+	}
+	return &ParseError{Row: f.at.row, Col: f.at.col, Snippet: s, Expected: f.exp}
+}
+`
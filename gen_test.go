@@ -0,0 +1,139 @@
+package calm
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const genGrammar = `
+Sum   <- Prod Op^ Sum / Prod
+Prod  <- Value { _ [*/] _ Value }
+Value <- _ (Num / '(' Sum _ ')') _
+Op    <- [-+]
+Num   <- '-'? [0-9]+ ('.' [0-9]+)?
+_     <- [ \t\n]*
+`
+
+func TestGenerate(t *testing.T) {
+
+	var out bytes.Buffer
+	err := Generate(&out, "parser", genGrammar)
+
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "// Code generated by calm gen. DO NOT EDIT.\n\npackage parser\n")
+	assert.Contains(t, out.String(), "func Parse(src string) (*AST, error) {")
+	assert.NotContains(t, out.String(), "github.com/ofabricio/calm")
+}
+
+func TestGenerate_Error(t *testing.T) {
+
+	var out bytes.Buffer
+
+	assert.EqualError(t, Generate(&out, "parser", "A <- B"), "undefined rule B at 1:6")
+	assert.EqualError(t, Generate(&out, "parser", "A <- ["), `1:7: unexpected end of input, expected S("]")`)
+	assert.EqualError(t, Generate(&out, "parser", "A <- 'a'\nB <- C 'b'\nC <- B / 'c'"), "left recursive rule B at 2:1")
+	assert.EqualError(t, Generate(&out, "parser", "A <- 'a'^ 'b'"), "root on the first or last item of a sequence at 1:9")
+	assert.EqualError(t, Generate(&out, "parser", "A <- 'a' ('b' 'c'^)"), "root on the first or last item of a sequence at 1:18")
+	assert.Empty(t, out.String())
+}
+
+// TestGenerate_Run compares the generated
+// parser with the matchers of Grammar.
+func TestGenerate_Run(t *testing.T) {
+	genRun(t, genGrammar, "Sum", "1", "1 + 2*3", "(1-2) / -3 + 4", "1 +", "x", "2 * (3", "12x")
+}
+
+func TestGenerate_Run_Empty(t *testing.T) {
+	genRun(t, "A <- 'a' A / ''", "A", "aaa", "", "ab")
+}
+
+func TestGenerate_Run_InfiniteLoop(t *testing.T) {
+	genRun(t, "A <- 'a' ('b'?)* 'c'", "A", "abbc", "ac", "x")
+}
+
+// genRun runs the parser generated from a grammar
+// and the rule of Grammar on the inputs and
+// compares their trees and errors.
+func genRun(t *testing.T, grammar, rule string, inputs ...string) {
+
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	// Given.
+
+	dir := t.TempDir()
+
+	var parser bytes.Buffer
+	assert.NoError(t, Generate(&parser, "main", grammar))
+
+	write := func(name, src string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644))
+	}
+	write("go.mod", "module gen\n\ngo 1.16\n")
+	write("parser.go", parser.String())
+	write("main.go", `package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	for _, in := range os.Args[1:] {
+		ast, err := Parse(in)
+		fmt.Println(short(ast), err)
+	}
+}
+
+func short(a *AST) string {
+	s := a.Type
+	if a.Name.Text != "" {
+		s += " " + a.Name.Text
+	}
+	if len(a.Args) > 0 {
+		var args []string
+		for _, b := range a.Args {
+			args = append(args, short(b))
+		}
+		s += " [ " + strings.Join(args, ", ") + " ]"
+	}
+	return s
+}
+`)
+
+	// When.
+
+	cmd := exec.Command(gobin, append([]string{"run", "."}, inputs...)...)
+	cmd.Dir = dir
+	got, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(got))
+
+	// Then.
+
+	rules, err := Grammar(grammar, nil)
+	assert.NoError(t, err)
+
+	var exp strings.Builder
+	for _, in := range inputs {
+		c := New(in)
+		err := rules[rule].RunErr(c)
+		exp.WriteString(c.ast.Print("short-inline") + " ")
+		if err != nil {
+			exp.WriteString(err.Error())
+		} else {
+			exp.WriteString("<nil>")
+		}
+		exp.WriteString("\n")
+	}
+
+	assert.Equal(t, exp.String(), string(got))
+}
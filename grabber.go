@@ -6,12 +6,59 @@ import "strconv"
 // when the current matcher returns true.
 func (m MatcherFunc) On(f func(Token)) MatcherFunc {
 	return func(c *Code) bool {
-		if ini := c.Mark(); m(c) {
+		ini := c.hold()
+		ok := m(c)
+		c.release()
+		if ok {
 			token := c.Token(ini, c.Mark())
-			f(token)
-			return true
+			c.emit(func() { f(token) })
+		}
+		return ok
+	}
+}
+
+// Transactional makes the code buffer the On callbacks
+// called inside Undo (and AND) until the outermost
+// Undo succeeds. When an Undo fails its callbacks are
+// discarded, as it happens with the AST nodes. Note
+// that a buffered callback can not be used by the
+// next matchers, for example a Grab used by SR.
+func (c *Code) Transactional() *Code {
+	c.tx = true
+	return c
+}
+
+// emit calls an event callback, or buffers it
+// in transactional mode when inside an Undo.
+func (c *Code) emit(e func()) {
+	if c.tx && c.txs > 0 {
+		c.events = append(c.events, e)
+		return
+	}
+	e()
+}
+
+// begin begins a transaction of events
+// and returns its start in the buffer.
+func (c *Code) begin() int {
+	c.txs++
+	return len(c.events)
+}
+
+// commit ends a transaction. The events from
+// n on are discarded if ok is false. Buffered
+// events are called by the outermost commit.
+func (c *Code) commit(n int, ok bool) {
+	c.txs--
+	if !ok {
+		c.events = c.events[:n]
+	}
+	if c.txs == 0 {
+		events := c.events
+		c.events = nil
+		for _, e := range events {
+			e()
 		}
-		return false
 	}
 }
 
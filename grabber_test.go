@@ -240,3 +240,48 @@ func TestBackReference_With_Grab_And_SR(t *testing.T) {
 		assert.Equal(t, tc.ok, ok, tc.in)
 	}
 }
+
+func TestTransactional(t *testing.T) {
+
+	tt := []struct {
+		in string
+		tx bool
+		ex []string
+	}{
+		{"ab ac", false, []string{"a", "b", "a", "a", "c"}},
+		{"ab ac", true, []string{"a", "b", "a", "c"}},
+		{"ax", false, []string{"a", "a"}},
+		{"ax", true, nil},
+	}
+
+	for _, tc := range tt {
+
+		c := New(tc.in)
+		if tc.tx {
+			c.Transactional()
+		}
+
+		var s []string
+		a := S("a").On(Grabs(&s))
+		b := S("b").On(Grabs(&s))
+		c2 := S("c").On(Grabs(&s))
+		Or(AND(a, b), AND(a, c2), S(" ")).OneToMany().Run(c)
+
+		assert.Equal(t, tc.ex, s, tc.in)
+	}
+}
+
+func TestTransactional_Order(t *testing.T) {
+
+	c := New("abc").Transactional()
+
+	var s []string
+	var inside []string
+	a := S("a").On(Grabs(&s))
+	b := S("b").On(func(Token) { inside = append([]string(nil), s...) })
+	ok := And(AND(a, b), S("c").On(Grabs(&s))).Run(c)
+
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "c"}, s)
+	assert.Equal(t, []string{"a"}, inside)
+}
@@ -0,0 +1,454 @@
+package calm
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Grammar compiles a PEG grammar into matchers,
+// one for each rule. For example:
+//
+//	# Comments start with '#'.
+//	Sum   <- Num (Op Num)*
+//	Op    <- [-+]
+//	Num   <- [0-9]+ / '(' Sum ')'
+//
+// A rule is defined with '<-', '=' or '::=' and
+// it may end with ';'. Rules are made of string
+// literals ('a' or "a"; an empty one always matches
+// without moving), character classes ([a-z],
+// [^0-9]), any character (.), rule references,
+// sequences (a b), choices (a / b or a | b),
+// repetitions (a*, a+, a? and the EBNF {a}),
+// predicates (&a, !a) and groups ((a b)). The
+// EBNF [a] is a character class, as in PEG, so
+// optional items are written a? instead.
+//
+// Rules may be left recursive, like
+// Sum <- Sum '+' Num / Num; see LeftRecursive.
+//
+// Rules starting with an upper case letter build
+// AST nodes with the rule name as type: a Leaf
+// when the rule builds no nodes itself or a Group
+// otherwise. Other rules do not build nodes. An
+// item marked with '^' in a sequence, like the Op
+// in Num Op^ Sum, becomes the parent of the nodes
+// around it, like in Root.
+//
+// The actions map gives matchers for references
+// that are not defined in the grammar. Each call
+// of a rule is a nesting level; see MaxDepth.
+func Grammar(src string, actions map[string]MatcherFunc) (map[string]MatcherFunc, error) {
+	rules, err := parseGrammar(src)
+	if err != nil {
+		return nil, err
+	}
+	g := &grammar{rules: map[string]MatcherFunc{}, sets: map[string]func(MatcherFunc) MatcherFunc{}, actions: actions}
+	left := leftRecursive(rules)
+	for _, r := range rules {
+		name := r.Left().Name.Text
+		if left[name] {
+			g.rules[name], g.sets[name] = LeftRecursive()
+		} else {
+			g.rules[name], g.sets[name] = Recursive()
+		}
+	}
+	for _, r := range rules {
+		name := r.Left().Name.Text
+		m, err := g.compile(r.Right())
+		if err != nil {
+			return nil, err
+		}
+		if unicode.IsUpper([]rune(name)[0]) {
+			m = m.node(name)
+		}
+		g.sets[name](m.Named(name))
+	}
+	return g.rules, nil
+}
+
+// parseGrammar parses a grammar into its rules.
+func parseGrammar(src string) ([]*AST, error) {
+	var ast AST
+	if err := grammarSyntax().Tree(&ast).RunErr(New(src)); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, r := range ast.Args {
+		name := r.Left().Name
+		if seen[name.Text] {
+			return nil, fmt.Errorf("rule %s redefined at %d:%d", name.Text, name.Row, name.Col)
+		}
+		seen[name.Text] = true
+		var err error
+		Walk(visitFunc(func(n *AST) {
+			if _, e := rootIndex(n); e != nil && err == nil {
+				err = e
+			}
+		}), r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ast.Args, nil
+}
+
+// rootIndex returns the index of the item
+// marked as root in a sequence, or -1.
+func rootIndex(seq *AST) (int, error) {
+	root := -1
+	if seq.Type != "Seq" {
+		return root, nil
+	}
+	for i, item := range seq.Args {
+		for _, a := range item.Args {
+			if a.Type != "Root" {
+				continue
+			}
+			if root >= 0 {
+				return root, fmt.Errorf("second root in a sequence at %d:%d", a.Name.Row, a.Name.Col)
+			}
+			root = i
+		}
+	}
+	return root, nil
+}
+
+// leftRecursive returns the rules that may
+// call themselves before consuming input.
+func leftRecursive(rules []*AST) map[string]bool {
+	nullable := map[string]bool{}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range rules {
+			name := r.Left().Name.Text
+			if _, null := lefts(r.Right(), nullable); null && !nullable[name] {
+				nullable[name], changed = true, true
+			}
+		}
+	}
+	calls := map[string][]string{}
+	for _, r := range rules {
+		calls[r.Left().Name.Text], _ = lefts(r.Right(), nullable)
+	}
+	left := map[string]bool{}
+	for _, r := range rules {
+		name := r.Left().Name.Text
+		seen := map[string]bool{}
+		next := calls[name]
+		for len(next) > 0 && !left[name] {
+			ref := next[0]
+			next = next[1:]
+			if ref == name {
+				left[name] = true
+			} else if !seen[ref] {
+				seen[ref] = true
+				next = append(next, calls[ref]...)
+			}
+		}
+	}
+	return left
+}
+
+// lefts returns the rules that a grammar syntax
+// node may call before consuming input, and if
+// it may match without consuming input.
+func lefts(n *AST, nullable map[string]bool) (refs []string, null bool) {
+	switch n.Type {
+	case "Choice":
+		for _, a := range n.Args {
+			rs, nl := lefts(a, nullable)
+			refs, null = append(refs, rs...), null || nl
+		}
+		return refs, null
+	case "Seq":
+		for _, a := range n.Args {
+			rs, nl := lefts(a, nullable)
+			if refs = append(refs, rs...); !nl {
+				return refs, false
+			}
+		}
+		return refs, true
+	case "Item":
+		var pred, rep string
+		for _, a := range n.Args {
+			switch a.Type {
+			case "Pred":
+				pred = a.Name.Text
+			case "Rep":
+				rep = a.Name.Text
+			case "Root":
+			default:
+				refs, null = lefts(a, nullable)
+			}
+		}
+		return refs, null || pred != "" || rep == "?" || rep == "*"
+	case "Many":
+		refs, _ = lefts(n.Args[0], nullable)
+		return refs, true
+	case "Ref":
+		return []string{n.Name.Text}, nullable[n.Name.Text]
+	case "Lit":
+		return nil, unquote(n.Name.Text) == ""
+	}
+	return nil, false
+}
+
+type visitFunc func(*AST)
+
+func (f visitFunc) Visit(n *AST) Visitor {
+	if n != nil {
+		f(n)
+	}
+	return f
+}
+
+// grammarSyntax returns the matcher of the grammar syntax.
+func grammarSyntax() MatcherFunc {
+	expr, setExpr := Recursive()
+	comment := And(S("#"), Until(Eq("\n")).True())
+	sp := Or(F(unicode.IsSpace), comment).ZeroToMany().quiet()
+	ident := And(Or(F(unicode.IsLetter), S("_")), Or(F(unicode.IsLetter), F(unicode.IsDigit), S("_")).ZeroToMany()).Named("identifier")
+	define := Or(S("<-"), S("::="), S("="))
+	literal := Or(grammarQuoted("'"), grammarQuoted(`"`)).Named("literal")
+	class := AND(S("["), Or(S(`\`).Next(), Until(Eq("]"), Eq(`\`))).ZeroToMany(), S("]")).Named("class")
+	ref := AND(ident.Leaf("Ref"), sp, define.Not())
+	primary := Or(
+		ref,
+		AND(S("("), sp, expr, S(")"), sp),
+		AND(S("{"), sp, expr, S("}"), sp).Group("Many"),
+		And(literal.Leaf("Lit"), sp),
+		And(class.Leaf("Class"), sp),
+		And(S(".").Leaf("Any"), sp),
+	).Named("expression")
+	pred := And(SOr("&!").Leaf("Pred"), sp).ZeroToOne()
+	rep := And(SOr("?*+").Leaf("Rep"), sp).ZeroToOne()
+	root := And(S("^").Leaf("Root"), sp).ZeroToOne()
+	item := AND(pred, primary, rep, root).Group("Item")
+	seq := item.ZeroToMany().Group("Seq")
+	choice := And(seq, And(SOr("/|"), sp, seq).ZeroToMany()).Group("Choice")
+	setExpr(choice)
+	rule := AND(ident.Leaf("Name"), sp, define, sp, expr, And(S(";"), sp).ZeroToOne()).Group("Rule")
+	return And(sp, rule.OneToMany(), Next().Not())
+}
+
+func grammarQuoted(q string) MatcherFunc {
+	return AND(S(q), Or(S(`\`).Next(), Until(Eq(q), Eq(`\`), Eq("\n"))).ZeroToMany(), S(q))
+}
+
+type grammar struct {
+	rules   map[string]MatcherFunc
+	sets    map[string]func(MatcherFunc) MatcherFunc
+	actions map[string]MatcherFunc
+}
+
+// compile compiles a grammar syntax node.
+func (g *grammar) compile(n *AST) (MatcherFunc, error) {
+	switch n.Type {
+	case "Choice", "Seq":
+		ms := make([]MatcherFunc, 0, len(n.Args))
+		for _, a := range n.Args {
+			m, err := g.compile(a)
+			if err != nil {
+				return nil, err
+			}
+			ms = append(ms, m)
+		}
+		if i, _ := rootIndex(n); i >= 0 {
+			return Root(AND(ms[:i]...), ms[i], AND(ms[i+1:]...)), nil
+		}
+		if len(ms) == 1 {
+			return ms[0], nil
+		}
+		if n.Type == "Choice" {
+			return Or(ms...), nil
+		}
+		return AND(ms...), nil
+	case "Item":
+		var pred, rep string
+		var m MatcherFunc
+		for _, a := range n.Args {
+			switch a.Type {
+			case "Pred":
+				pred = a.Name.Text
+			case "Rep":
+				rep = a.Name.Text
+			case "Root":
+			default:
+				var err error
+				if m, err = g.compile(a); err != nil {
+					return nil, err
+				}
+			}
+		}
+		switch rep {
+		case "?":
+			m = m.ZeroToOne()
+		case "*":
+			m = m.ZeroToMany()
+		case "+":
+			m = m.OneToMany()
+		}
+		switch pred {
+		case "&":
+			m = m.ahead()
+		case "!":
+			m = m.ahead().Not()
+		}
+		return m, nil
+	case "Many":
+		m, err := g.compile(n.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		return m.ZeroToMany(), nil
+	case "Ref":
+		if m, ok := g.rules[n.Name.Text]; ok {
+			return m, nil
+		}
+		if m, ok := g.actions[n.Name.Text]; ok {
+			return m, nil
+		}
+		return nil, fmt.Errorf("undefined rule %s at %d:%d", n.Name.Text, n.Name.Row, n.Name.Col)
+	case "Lit":
+		if unquote(n.Name.Text) == "" {
+			return True(), nil
+		}
+		return S(unquote(n.Name.Text)), nil
+	case "Class":
+		return class(n.Name.Text), nil
+	case "Any":
+		return Next(), nil
+	}
+	panic("unknown grammar node " + n.Type)
+}
+
+// class compiles a character class like [a-z_].
+func class(text string) MatcherFunc {
+	set, neg := parseClass(text)
+	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
+		if c.More() && set.has(c.Curr()) != neg {
+			c.Next()
+			return true
+		}
+		c.expect(text)
+		return false
+	}
+}
+
+// parseClass parses a character class
+// and tells if it is negated, like [^a].
+func parseClass(text string) (set charSet, neg bool) {
+	type char struct {
+		r   rune
+		esc bool
+	}
+	var cs []char
+	esc := false
+	for _, r := range text[1 : len(text)-1] {
+		if r == '\\' && !esc {
+			esc = true
+			continue
+		}
+		cs = append(cs, char{r: unescape(r, esc), esc: esc})
+		esc = false
+	}
+	if neg = len(cs) > 0 && cs[0] == (char{r: '^'}); neg {
+		cs = cs[1:]
+	}
+	for i := 0; i < len(cs); i++ {
+		if i+2 < len(cs) && cs[i+1] == (char{r: '-'}) {
+			set = append(set, charRange{cs[i].r, cs[i+2].r})
+			i += 2
+		} else {
+			set = append(set, charRange{cs[i].r, cs[i].r})
+		}
+	}
+	return set, neg
+}
+
+type charSet []charRange
+
+type charRange struct{ lo, hi rune }
+
+func (s charSet) has(r rune) bool {
+	for _, cr := range s {
+		if cr.lo <= r && r <= cr.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// unquote removes the quotes of a literal and its escapes.
+func unquote(s string) string {
+	var b strings.Builder
+	esc := false
+	for _, r := range s[1 : len(s)-1] {
+		if r == '\\' && !esc {
+			esc = true
+			continue
+		}
+		b.WriteRune(unescape(r, esc))
+		esc = false
+	}
+	return b.String()
+}
+
+// unescape returns the character of an escape
+// sequence, like n in \n, when esc is true.
+func unescape(r rune, esc bool) rune {
+	if esc {
+		switch r {
+		case 'n':
+			return '\n'
+		case 'r':
+			return '\r'
+		case 't':
+			return '\t'
+		}
+	}
+	return r
+}
+
+// node builds a Leaf node when the current
+// matcher builds no nodes or a Group otherwise.
+func (m MatcherFunc) node(Type string) MatcherFunc {
+	return func(c *Code) bool {
+		parent := c.ast
+		node := &AST{Type: Type}
+		c.ast = node
+		ini := c.hold()
+		ok := m(c)
+		c.release()
+		c.ast = parent
+		if ok {
+			node.Span = c.span(ini, c.Mark())
+			if len(node.Args) == 0 {
+				node.Name = c.Token(ini, c.Mark())
+			}
+			parent.Args = append(parent.Args, node)
+		}
+		return ok
+	}
+}
+
+// ahead tests the current matcher, but does not
+// move the position, build nodes nor keep events.
+func (m MatcherFunc) ahead() MatcherFunc {
+	return func(c *Code) bool {
+		parent := c.ast
+		c.ast = &AST{Type: "Ahead"}
+		ini, n := c.hold(), c.begin()
+		ok := m(c)
+		c.release()
+		c.commit(n, false)
+		c.Back(ini)
+		c.ast = parent
+		return ok
+	}
+}
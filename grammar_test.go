@@ -0,0 +1,126 @@
+package calm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrammar(t *testing.T) {
+
+	// Given.
+
+	src := `
+	# Arithmetic.
+	Sum   <- Prod (_ Op _ Prod)*
+	Prod  <- Value (_ [*/] _ Value)*
+	Value <- Num / '(' _ Sum _ ')'
+	Op    =  '+' | '-' ;
+	Num   ::= [0-9]+ ('.' [0-9]+)?
+	_     <- [ \t]*
+	`
+
+	tt := []struct {
+		in  string
+		exp string
+		err string
+	}{
+		{"1", "Root [ Sum [ Prod [ Value [ Num 1 ] ] ] ]", ""},
+		{"1.5 + 2", "Root [ Sum [ Prod [ Value [ Num 1.5 ] ], Op +, Prod [ Value [ Num 2 ] ] ] ]", ""},
+		{"2*(3-4)", "Root [ Sum [ Prod [ Value [ Num 2 ], Value [ Sum [ Prod [ Value [ Num 3 ] ], Op -, Prod [ Value [ Num 4 ] ] ] ] ] ] ]", ""},
+		{"x", "Root", `1:1: unexpected "x", expected Sum`},
+		{"(1", "Root", `1:3: unexpected end of input, expected [0-9] or S(".") or [ \t] or [*/] or Op or S(")")`},
+	}
+
+	// When.
+
+	rules, err := Grammar(src, nil)
+
+	// Then.
+
+	assert.NoError(t, err)
+
+	for _, tc := range tt {
+
+		c := New(tc.in)
+
+		err := rules["Sum"].RunErr(c)
+
+		if tc.err == "" {
+			assert.NoError(t, err, tc.in)
+		} else {
+			assert.EqualError(t, err, tc.err, tc.in)
+		}
+		assert.Equal(t, tc.exp, c.ast.Print("short-inline"), tc.in)
+	}
+}
+
+func TestGrammar_Syntax(t *testing.T) {
+
+	tt := []struct {
+		gr  string
+		in  string
+		ok  bool
+		exp string
+	}{
+		{`A <- 'a' "b"`, "ab", true, "Root [ A ab ]"},
+		{`A <- 'a\n\''`, "a\n'", true, "Root [ A a\n' ]"},
+		{`A <- [a-c]+`, "abcd", true, "Root [ A abc ]"},
+		{`A <- [^a-c]+`, "xyza", true, "Root [ A xyz ]"},
+		{`A <- [\]\-]+`, "]-]", true, "Root [ A ]-] ]"},
+		{`A <- .`, "x", true, "Root [ A x ]"},
+		{`A <- .`, "", false, "Root"},
+		{`A <- 'a'?`, "", true, "Root [ A ]"},
+		{`A <- !'b' .`, "a", true, "Root [ A a ]"},
+		{`A <- !'b' .`, "b", false, "Root"},
+		{`A <- &'a' .`, "a", true, "Root [ A a ]"},
+		{`A <- &'a' .`, "b", false, "Root"},
+		{`A <- b c  b <- 'b'  c <- 'c'`, "bc", true, "Root [ A bc ]"},
+		{`A <- B C  B <- 'b'  C <- 'c'`, "bc", true, "Root [ A [ B b, C c ] ]"},
+		{`A <- 'a' 'b' / 'a' 'c'`, "ac", true, "Root [ A ac ]"},
+		{`A <- ('a' / 'b')+ 'c'`, "abac", true, "Root [ A abac ]"},
+		{`A <- 'a' { 'b' / 'c' } 'd'`, "abcbd", true, "Root [ A abcbd ]"},
+		{`A <- {'b'} 'a'`, "a", true, "Root [ A a ]"},
+		{`A <- A 'a' / 'a'`, "aaa", true, "Root [ A [ A [ A a ] ] ]"},
+		{`A <- b  b <- b '+' N / N`, "1+2+3", true, "Root [ A [ N 1, N 2, N 3 ] ]"},
+		{`A <- A Op^ N / N  Op <- '+'`, "1+2+3", true, "Root [ A [ Op + [ A [ Op + [ A [ N 1 ], N 2 ] ], N 3 ] ] ]"},
+		{`A <- b 'x'  b <- A '-' / 'z'`, "zx-x", true, "Root [ A [ A zx ] ]"},
+		{`A <- N ',' N`, "1,2", true, "Root [ A [ N 1, N 2 ] ]"},
+		{`A <- N Op^ A / N  Op <- '+'`, "1+2+3", true, "Root [ A [ Op + [ N 1, A [ Op + [ N 2, A [ N 3 ] ] ] ] ] ]"},
+	}
+
+	for _, tc := range tt {
+
+		rules, err := Grammar(tc.gr, map[string]MatcherFunc{"N": Number().Leaf("N")})
+		assert.NoError(t, err, tc.gr)
+
+		c := New(tc.in)
+
+		ok := rules["A"].Run(c)
+
+		assert.Equal(t, tc.ok, ok, tc.gr)
+		assert.Equal(t, tc.exp, c.ast.Print("short-inline"), tc.gr)
+	}
+}
+
+func TestGrammar_Error(t *testing.T) {
+
+	tt := []struct {
+		gr  string
+		err string
+	}{
+		{`A <- B`, "undefined rule B at 1:6"},
+		{"A <- 'a'\nA <- 'b'", "rule A redefined at 2:1"},
+		{`A <- 'a`, `1:8: unexpected end of input, expected S("'")`},
+		{`A <- ('a'`, `1:10: unexpected end of input, expected SOr("?*+") or S("^") or SOr("&!") or expression or SOr("/|") or S(")")`},
+		{`<- 'a'`, `1:1: unexpected "<- 'a'", expected identifier`},
+		{`A <- 'a'^ 'b'^`, "second root in a sequence at 1:14"},
+	}
+
+	for _, tc := range tt {
+
+		_, err := Grammar(tc.gr, nil)
+
+		assert.EqualError(t, err, tc.err, tc.gr)
+	}
+}
@@ -0,0 +1,108 @@
+package calm
+
+// Indent tests if the current line is more indented
+// than the current indentation level. If true it
+// moves the position past the indentation and
+// pushes the line indentation as the new level.
+// Blank lines before it are skipped. Spaces and
+// tabs count as one column each. For example, a
+// block is And(Indent(), stmt, And(SameIndent(),
+// stmt).ZeroToMany(), Dedent()).
+func Indent() MatcherFunc {
+	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
+		ini := c.hold()
+		n, ok := c.measure()
+		c.release()
+		if ok && n > c.ind.width() {
+			c.ind = &indent{n: n, prev: c.ind}
+			return true
+		}
+		c.expect("Indent()")
+		c.Back(ini)
+		return false
+	}
+}
+
+// SameIndent tests if the current line is indented
+// at the current indentation level and moves the
+// position past the indentation if true. Blank
+// lines before it are skipped.
+func SameIndent() MatcherFunc {
+	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
+		ini := c.hold()
+		n, ok := c.measure()
+		c.release()
+		if ok && n == c.ind.width() {
+			return true
+		}
+		c.expect("SameIndent()")
+		c.Back(ini)
+		return false
+	}
+}
+
+// Dedent tests if the current line is less indented
+// than the current indentation level or if there
+// is no more code. If true it pops the level.
+// It does not move the position.
+func Dedent() MatcherFunc {
+	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
+		ini := c.hold()
+		n, ok := c.measure()
+		c.release()
+		if c.ind != nil && (!c.More() || ok && n < c.ind.n) {
+			c.Back(ini)
+			c.ind = c.ind.prev
+			return true
+		}
+		c.expect("Dedent()")
+		c.Back(ini)
+		return false
+	}
+}
+
+// measure skips blank lines and the indentation of
+// the current line and returns its width. It is
+// false when not at the start of a line or when
+// there is no more code.
+func (c *Code) measure() (int, bool) {
+	if c.col != 1 {
+		return 0, false
+	}
+	for c.More() {
+		ini := c.pos
+		for c.More() && (c.Curr() == ' ' || c.Curr() == '\t') {
+			c.Next()
+		}
+		if c.More() && (c.Curr() == '\n' || c.Curr() == '\r') {
+			c.Next()
+			continue
+		}
+		return c.pos - ini, c.More()
+	}
+	return 0, false
+}
+
+// indent is an indentation stack. It is never
+// changed, so marks can keep it to go back.
+type indent struct {
+	n    int
+	prev *indent
+}
+
+// width returns the top indentation level.
+func (i *indent) width() int {
+	if i == nil {
+		return 0
+	}
+	return i.n
+}
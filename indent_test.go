@@ -0,0 +1,106 @@
+package calm
+
+import (
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndent(t *testing.T) {
+
+	block, setBlock := Recursive()
+	name := F(unicode.IsLetter).OneToMany().Leaf("Name")
+	eol := Or(S("\n"), Next().Not())
+	stmt := Or(AND(name, S(":"), eol, block).Group("Block"), AND(name, eol))
+	setBlock(AND(Indent(), stmt, And(SameIndent(), stmt).ZeroToMany(), Dedent()))
+	root := AND(stmt, And(SameIndent(), stmt).ZeroToMany(), Next().Not())
+
+	tt := []struct {
+		in string
+		ok bool
+		ex string
+	}{
+		{"a", true, "[Name]"},
+		{"a\nb", true, "[Name Name]"},
+		{"a:\n  b", true, "[[Name Name]]"},
+		{"a:\n  b\nc", true, "[[Name Name] Name]"},
+		{"a:\n  b\n  c\nd", true, "[[Name Name Name] Name]"},
+		{"a:\n  b:\n    c\n  d\ne", true, "[[Name [Name Name] Name] Name]"},
+		{"a:\n  b:\n    c\ne", true, "[[Name [Name Name]] Name]"},
+		{"a:\n\n  b\n   \n  c\n\nd\n", true, "[[Name Name Name] Name]"},
+		{"a:\n\tb", true, "[[Name Name]]"},
+		{"a:\nb", false, ""},
+		{"a:\n  b\n    c", false, ""},
+		{"a:\n    b\n  c", false, ""},
+		{" a", false, ""},
+	}
+
+	for _, tc := range tt {
+
+		c := New(tc.in)
+
+		var ast AST
+		ok := root.Tree(&ast).Run(c)
+
+		assert.Equal(t, tc.ok, ok, tc.in)
+		if tc.ok {
+			assert.Equal(t, tc.ex, types(&ast), tc.in)
+		}
+	}
+}
+
+func TestIndent_Back(t *testing.T) {
+
+	c := New("a\n  b")
+
+	ini := c.Mark()
+	ok := AND(S("a\n"), Indent(), S("x")).Run(c)
+
+	assert.False(t, ok)
+	assert.Equal(t, ini, c.Mark())
+	assert.Nil(t, c.ind)
+
+	ok = And(S("a\n"), Indent(), S("b"), Dedent()).Run(c)
+
+	assert.True(t, ok)
+	assert.Nil(t, c.ind)
+}
+
+func TestIndent_Error(t *testing.T) {
+
+	tt := []struct {
+		in string
+		ex string
+	}{
+		{"a:\nb", `2:1: unexpected "b", expected Indent()`},
+		{"a:\n  b\n c", `3:2: unexpected "c", expected SameIndent()`},
+	}
+
+	for _, tc := range tt {
+
+		c := New(tc.in)
+
+		name := F(unicode.IsLetter)
+		block := AND(Indent(), name, And(S("\n"), SameIndent(), name).ZeroToMany(), Dedent())
+		err := AND(name, S(":\n"), block, Next().Not()).RunErr(c)
+
+		assert.EqualError(t, err, tc.ex, tc.in)
+	}
+}
+
+// types returns the node types of
+// an AST like [Name [Name Name]].
+func types(n *AST) string {
+	if len(n.Args) == 0 {
+		return n.Type
+	}
+	s := "["
+	for i, a := range n.Args {
+		if i > 0 {
+			s += " "
+		}
+		s += types(a)
+	}
+	return s + "]"
+}
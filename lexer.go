@@ -0,0 +1,135 @@
+package calm
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// Lexer splits a source code into tokens.
+type Lexer struct {
+	// Rules match the tokens by kind.
+	Rules []Rule
+	// Skip matches what is between
+	// tokens, like spaces. Optional.
+	Skip MatcherFunc
+}
+
+// Rule matches the tokens of a kind.
+type Rule struct {
+	Kind    string
+	Matcher MatcherFunc
+}
+
+// Lex splits a source code into tokens. Each token
+// is the longest match among the rules. When rules
+// tie the one declared first wins.
+func (l Lexer) Lex(src string) ([]Token, error) {
+	c := New(src)
+	var toks []Token
+	for {
+		for l.Skip != nil && c.More() {
+			ini := c.Mark()
+			if !l.Skip(c) || c.pos == ini.pos {
+				break
+			}
+		}
+		if !c.More() {
+			return toks, nil
+		}
+		c.fail = c.failure()
+		ini, end := c.Mark(), c.Mark()
+		kind := ""
+		for _, r := range l.Rules {
+			if r.Matcher(c) && c.pos > end.pos {
+				kind, end = r.Kind, c.Mark()
+			}
+			c.Back(ini)
+		}
+		if kind == "" {
+			return toks, c.parseError()
+		}
+		c.Back(end)
+		tok := c.Token(ini, end)
+		tok.Kind = kind
+		toks = append(toks, tok)
+	}
+}
+
+// Kind tests if the current token is
+// of a kind and moves the position if
+// true. It only works on token codes.
+func Kind(kind string) MatcherFunc {
+	exp := "Kind(" + strconv.Quote(kind) + ")"
+	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
+		if c.toks != nil && c.More() && c.toks[c.pos].Kind == kind {
+			c.Next()
+			return true
+		}
+		c.expect(exp)
+		return false
+	}
+}
+
+// Lit tests if the current token is a literal
+// string and moves the position if true. It
+// is like S, but its name is better suited
+// for token codes.
+func Lit(s string) MatcherFunc {
+	exp := "Lit(" + strconv.Quote(s) + ")"
+	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
+		if c.Match(s) {
+			return true
+		}
+		c.expect(exp)
+		return false
+	}
+}
+
+// char returns the current character and
+// true if there is one. In a token code the
+// whole token must be that one character.
+func (c *Code) char() (rune, bool) {
+	if !c.More() {
+		return utf8.RuneError, false
+	}
+	if c.toks != nil {
+		r, n := utf8.DecodeRuneInString(c.toks[c.pos].Text)
+		return r, n == len(c.toks[c.pos].Text)
+	}
+	return c.Curr(), true
+}
+
+// tokpos updates the row and column
+// to the ones of the current token.
+func (c *Code) tokpos() {
+	if c.More() {
+		c.row, c.col = c.toks[c.pos].Row, c.toks[c.pos].Col
+	} else if n := len(c.toks); n > 0 {
+		last := c.toks[n-1]
+		m := c.after(Mark{row: last.Row, col: last.Col}, last.Text)
+		c.row, c.col = m.row, m.col
+	}
+}
+
+// tokens returns the tokens between ini and end as one.
+func (c *Code) tokens(ini, end Mark) Token {
+	if ini.pos >= end.pos {
+		pos := len(c.src)
+		if ini.pos < len(c.toks) {
+			pos = c.toks[ini.pos].Pos
+		}
+		return Token{Pos: pos, Row: ini.row, Col: ini.col}
+	}
+	first, last := c.toks[ini.pos], c.toks[end.pos-1]
+	tok := Token{Text: c.src[first.Pos : last.Pos+len(last.Text)], Pos: first.Pos, Row: first.Row, Col: first.Col}
+	if end.pos-ini.pos == 1 {
+		tok.Kind = first.Kind
+	}
+	return tok
+}
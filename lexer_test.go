@@ -0,0 +1,145 @@
+package calm
+
+import (
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLexer(t *testing.T) {
+
+	l := Lexer{
+		Rules: []Rule{
+			{"Ident", F(unicode.IsLetter).OneToMany()},
+			{"Int", F(unicode.IsDigit).OneToMany()},
+			{"Op", Or(S("=="), SOr("=+"))},
+			{"Str", String(`"`)},
+		},
+		Skip: F(unicode.IsSpace).OneToMany(),
+	}
+
+	tt := []struct {
+		in  string
+		exp []Token
+		err string
+	}{
+		{"", nil, ""},
+		{"  ", nil, ""},
+		{"a", []Token{{Text: "a", Pos: 0, Row: 1, Col: 1, Kind: "Ident"}}, ""},
+		{"ab == 12\n\"c\"", []Token{
+			{Text: "ab", Pos: 0, Row: 1, Col: 1, Kind: "Ident"},
+			{Text: "==", Pos: 3, Row: 1, Col: 4, Kind: "Op"},
+			{Text: "12", Pos: 6, Row: 1, Col: 7, Kind: "Int"},
+			{Text: `"c"`, Pos: 9, Row: 2, Col: 1, Kind: "Str"},
+		}, ""},
+		{"a ?", []Token{{Text: "a", Pos: 0, Row: 1, Col: 1, Kind: "Ident"}}, `1:3: unexpected "?", expected F(unicode.IsLetter) or F(unicode.IsDigit) or S("==") or SOr("=+") or string`},
+	}
+
+	for _, tc := range tt {
+
+		toks, err := l.Lex(tc.in)
+
+		assert.Equal(t, tc.exp, toks, tc.in)
+		if tc.err == "" {
+			assert.NoError(t, err, tc.in)
+		} else {
+			assert.EqualError(t, err, tc.err, tc.in)
+		}
+	}
+}
+
+func TestLexer_Longest(t *testing.T) {
+
+	l := Lexer{
+		Rules: []Rule{
+			{"Keyword", Or(S("if"), S("func"))},
+			{"Ident", F(unicode.IsLetter).OneToMany()},
+		},
+		Skip: S(" "),
+	}
+
+	toks, err := l.Lex("if iff func funcs")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Keyword", "Ident", "Keyword", "Ident"}, kinds(toks))
+
+	l.Rules = []Rule{l.Rules[1], l.Rules[0]}
+	toks, _ = l.Lex("if iff func")
+
+	assert.Equal(t, []string{"Ident", "Ident", "Ident"}, kinds(toks))
+
+	l.Rules = append([]Rule{{"If", S("if")}}, l.Rules[1])
+	toks, _ = l.Lex("if func")
+
+	assert.Equal(t, []string{"If", "Keyword"}, kinds(toks))
+}
+
+func TestNewTokens(t *testing.T) {
+
+	src := "func main ( ) {\n}"
+	toks, err := Lexer{
+		Rules: []Rule{
+			{"Ident", F(unicode.IsLetter).OneToMany()},
+			{"Punct", SOr("(){}")},
+		},
+		Skip: F(unicode.IsSpace).OneToMany(),
+	}.Lex(src)
+	assert.NoError(t, err)
+
+	fun := AND(Lit("func"), Kind("Ident").Leaf("Name"), Lit("("), Lit(")"), Lit("{"), Lit("}")).Group("Func")
+
+	var ast AST
+	c := NewTokens(src, toks)
+	ok := fun.Tree(&ast).Run(c)
+
+	assert.True(t, ok)
+	assert.False(t, c.More())
+	assert.Equal(t, &AST{Type: "Name", Name: Token{Text: "main", Pos: 5, Row: 1, Col: 6, Kind: "Ident"}, Span: Span{Mark{pos: 5, row: 1, col: 6}, Mark{pos: 9, row: 1, col: 10}}}, ast.Args[0].Args[0])
+	assert.Equal(t, Span{Mark{pos: 0, row: 1, col: 1}, Mark{pos: 17, row: 2, col: 2}}, ast.Args[0].Span)
+	assert.Equal(t, src, ast.Args[0].Text(src))
+	assert.Equal(t, Token{Text: "func main ( ) {\n}", Pos: 0, Row: 1, Col: 1}, c.Token(Mark{row: 1, col: 1}, c.Mark()))
+	assert.Equal(t, 2, c.Mark().row)
+	assert.Equal(t, 2, c.Mark().col)
+
+	c = NewTokens(src, toks)
+	err = AND(Lit("func"), Kind("Punct")).RunErr(c)
+
+	assert.EqualError(t, err, `1:6: unexpected "main ( ) {", expected Kind("Punct")`)
+}
+
+func TestNewTokens_Char(t *testing.T) {
+
+	src := "== = ab a"
+	toks, err := Lexer{
+		Rules: []Rule{
+			{"Ident", F(unicode.IsLetter).OneToMany()},
+			{"Op", Or(S("=="), S("="))},
+		},
+		Skip: S(" "),
+	}.Lex(src)
+	assert.NoError(t, err)
+
+	tt := []struct {
+		give MatcherFunc
+		then bool
+	}{
+		{SOr("="), false},
+		{AND(Next(), SOr("=")), true},
+		{AND(Next(), Next(), F(unicode.IsLetter)), false},
+		{AND(Next(), Next(), Next(), F(unicode.IsLetter)), true},
+	}
+
+	for _, tc := range tt {
+		c := NewTokens(src, toks)
+		assert.Equal(t, tc.then, tc.give.Run(c))
+	}
+}
+
+func kinds(toks []Token) []string {
+	var ks []string
+	for _, t := range toks {
+		ks = append(ks, t.Kind)
+	}
+	return ks
+}
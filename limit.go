@@ -0,0 +1,127 @@
+package calm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrCanceled is returned when the context of the code is done.
+	ErrCanceled = errors.New("parse canceled")
+	// ErrBudgetExceeded is returned when the code takes more
+	// steps or backtracks than allowed by MaxSteps or MaxBacktracks.
+	ErrBudgetExceeded = errors.New("parse budget exceeded")
+	// ErrInfiniteLoop is returned when a repetition
+	// matches without moving the position.
+	ErrInfiniteLoop = errors.New("infinite loop")
+	// ErrMaxDepth is returned when the
+	// nesting goes deeper than MaxDepth.
+	ErrMaxDepth = errors.New("max depth exceeded")
+)
+
+// ctxEvery is how many steps are taken
+// between checks of the context.
+const ctxEvery = 1024
+
+// WithContext stops the scan when the context is done.
+// Matchers fail from then on and RunErr returns an
+// error that wraps ErrCanceled.
+func (c *Code) WithContext(ctx context.Context) *Code {
+	c.ctx = ctx
+	return c
+}
+
+// MaxSteps stops the scan after n steps. A step is
+// one run of a matcher of this package, like S, F,
+// And or Or. Matchers that only wrap another one,
+// like Named or Tree, do not count. RunErr returns
+// an error that wraps ErrBudgetExceeded.
+func (c *Code) MaxSteps(n int) *Code {
+	c.maxSteps = n
+	return c
+}
+
+// MaxBacktracks stops the scan after the position
+// goes back n times. RunErr returns an error that
+// wraps ErrBudgetExceeded.
+func (c *Code) MaxBacktracks(n int) *Code {
+	c.maxBacks = n
+	return c
+}
+
+// MaxDepth stops the scan when recursive matchers
+// nest deeper than n levels, which prevents stack
+// overflows on hostile input like "[[[[...".
+// RunErr returns an error that wraps ErrMaxDepth.
+func (c *Code) MaxDepth(n int) *Code {
+	c.maxDepth = n
+	return c
+}
+
+// Err returns the error that stopped the scan, if any.
+func (c *Code) Err() error {
+	return c.err
+}
+
+// step counts a step and tells if the scan can go on.
+func (c *Code) step() bool {
+	if c.err != nil {
+		return false
+	}
+	c.steps++
+	if c.maxSteps > 0 && c.steps > c.maxSteps {
+		c.abort(fmt.Errorf("%w: more than %d steps", ErrBudgetExceeded, c.maxSteps))
+		return false
+	}
+	if c.ctx != nil && c.steps%ctxEvery == 0 {
+		if err := c.ctx.Err(); err != nil {
+			c.abort(fmt.Errorf("%w: %v", ErrCanceled, err))
+			return false
+		}
+	}
+	return true
+}
+
+// enter enters a nesting level
+// and tells if the scan can go on.
+func (c *Code) enter() bool {
+	c.depth++
+	if c.maxDepth > 0 && c.depth > c.maxDepth {
+		c.abort(ErrMaxDepth)
+	}
+	return c.err == nil
+}
+
+// leave leaves a nesting level.
+func (c *Code) leave() {
+	c.depth--
+}
+
+// backtrack counts a backtrack.
+func (c *Code) backtrack() {
+	c.backs++
+	if c.maxBacks > 0 && c.backs > c.maxBacks {
+		c.abort(fmt.Errorf("%w: more than %d backtracks", ErrBudgetExceeded, c.maxBacks))
+	}
+}
+
+// stuck tells if a repetition that matched
+// from ini did not move the position, which
+// means it would repeat forever. If so it
+// stops the scan.
+func (c *Code) stuck(ini Mark) bool {
+	if c.pos == ini.pos && c.ind == ini.ind {
+		c.abort(ErrInfiniteLoop)
+		return true
+	}
+	return false
+}
+
+// abort stops the scan with an error. Only
+// the first error is kept.
+func (c *Code) abort(err error) {
+	if c.err == nil {
+		c.err = fmt.Errorf("%w at %d:%d", err, c.row, c.col)
+	}
+}
@@ -0,0 +1,206 @@
+package calm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfiniteLoop(t *testing.T) {
+
+	tt := []struct {
+		in string
+		m  MatcherFunc
+		ex string
+	}{
+		{"abc", Eq("a").ZeroToMany(), "infinite loop at 1:1"},
+		{"abc", And(S("a"), S("x").True().OneToMany()), "infinite loop at 1:2"},
+		{"abc", Or(S("a"), S("b"), Eq("c")).Min(2), "infinite loop at 1:3"},
+		{"abc", Or(S("a"), S("b"), Eq("c")).Min(5), "infinite loop at 1:3"},
+		{"abc", F(unicode.IsLetter).ZeroToMany(), ""},
+	}
+
+	for _, tc := range tt {
+
+		c := New(tc.in)
+
+		err := tc.m.RunErr(c)
+
+		if tc.ex == "" {
+			assert.NoError(t, err, tc.in)
+		} else {
+			assert.EqualError(t, err, tc.ex, tc.in)
+			assert.True(t, errors.Is(err, ErrInfiniteLoop), tc.in)
+			assert.Equal(t, err, c.Err(), tc.in)
+		}
+	}
+}
+
+func TestInfiniteLoop_Parser(t *testing.T) {
+
+	c := New("abc")
+
+	_, err := Many(Opt(Text(S("x")), "")).Parse(c)
+
+	assert.True(t, errors.Is(err, ErrInfiniteLoop))
+}
+
+func TestAbort(t *testing.T) {
+
+	tt := []MatcherFunc{
+		Or(S("a").OneToMany(), True()),
+		And(S("a").OneToMany(), Next().Not()),
+		And(S("a").OneToMany(), S("b").ZeroToOne()),
+		And(S("a").OneToMany(), Dedent().Not()),
+		Or(S("a").OneToMany().Memo(), True()).Memo(),
+	}
+
+	for _, m := range tt {
+
+		c := New("aaaaaaaa").MaxSteps(4)
+
+		ok := m.Run(c)
+
+		assert.False(t, ok)
+		assert.True(t, errors.Is(c.Err(), ErrBudgetExceeded))
+		assert.False(t, True().Run(c))
+		assert.False(t, c.More())
+		assert.False(t, c.Equal("a"))
+	}
+}
+
+func TestMaxSteps(t *testing.T) {
+
+	tt := []struct {
+		in string
+		n  int
+		ex string
+	}{
+		{"aaaa", 0, ""},
+		{"aaaa", 6, ""},
+		{"aaaa", 5, "parse budget exceeded: more than 5 steps at 1:5"},
+		{"aaaa", 3, "parse budget exceeded: more than 3 steps at 1:3"},
+	}
+
+	for _, tc := range tt {
+
+		c := New(tc.in).MaxSteps(tc.n)
+
+		err := S("a").ZeroToMany().RunErr(c)
+
+		if tc.ex == "" {
+			assert.NoError(t, err, tc.in)
+		} else {
+			assert.EqualError(t, err, tc.ex, tc.in)
+			assert.True(t, errors.Is(err, ErrBudgetExceeded), tc.in)
+		}
+	}
+}
+
+func TestMaxBacktracks(t *testing.T) {
+
+	tt := []struct {
+		n  int
+		ex string
+	}{
+		{0, ""},
+		{2, ""},
+		{1, "parse budget exceeded: more than 1 backtracks at 1:4"},
+	}
+
+	for _, tc := range tt {
+
+		c := New("ab ab ac").MaxBacktracks(tc.n)
+
+		err := Or(AND(S("a"), S("c")), AND(S("a"), S("b")), S(" ")).ZeroToMany().RunErr(c)
+
+		if tc.ex == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, tc.ex)
+			assert.True(t, errors.Is(err, ErrBudgetExceeded))
+		}
+	}
+}
+
+func TestWithContext(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := New(string(make([]byte, 10000))).WithContext(ctx)
+
+	err := Next().ZeroToMany().RunErr(c)
+
+	assert.True(t, errors.Is(err, ErrCanceled))
+	assert.EqualError(t, err, "parse canceled: context canceled at 1:1023")
+
+	c = New("abc").WithContext(context.Background())
+
+	err = Next().ZeroToMany().RunErr(c)
+
+	assert.NoError(t, err)
+}
+
+func TestMaxDepth(t *testing.T) {
+
+	tt := []struct {
+		in string
+		m  MatcherFunc
+		n  int
+		ex string
+	}{
+		{"[[1]]", Json(), 2, ""},
+		{"[[[1]]]", Json(), 2, "max depth exceeded at 1:3"},
+		{strings.Repeat("[", 1000000), Json(), 100, "max depth exceeded at 1:101"},
+		{"<<>>", Tag("<", ">"), 2, ""},
+		{"<<<>>>", Tag("<", ">"), 2, "max depth exceeded at 1:3"},
+		{"[[[[1]]]]", Json(), 0, ""},
+	}
+
+	for _, tc := range tt {
+
+		c := New(tc.in).MaxDepth(tc.n)
+
+		err := tc.m.RunErr(c)
+
+		if tc.ex == "" {
+			assert.NoError(t, err, tc.in)
+		} else {
+			assert.EqualError(t, err, tc.ex)
+			assert.True(t, errors.Is(err, ErrMaxDepth))
+		}
+	}
+}
+
+func TestMaxDepth_LeftRecursive(t *testing.T) {
+
+	expr, setExpr := LeftRecursive()
+	setExpr(Or(And(expr, S("+"), S("1")), And(S("("), expr, S(")")), S("1")))
+
+	err := expr.RunErr(New("((1+1))").MaxDepth(3))
+
+	assert.NoError(t, err)
+
+	err = expr.RunErr(New("(((1+1)))").MaxDepth(3))
+
+	assert.EqualError(t, err, "max depth exceeded at 1:4")
+}
+
+func TestMaxDepth_Lazy(t *testing.T) {
+
+	list, setList := Lazy[int]()
+	setList(Choice(Map(Between(S("("), list, S(")")), func(n int) int { return n + 1 }), Map(Text(S("x")), func(string) int { return 0 })))
+
+	_, err := list.Parse(New("((x))").MaxDepth(3))
+
+	assert.NoError(t, err)
+
+	_, err = list.Parse(New("(((x)))").MaxDepth(3))
+
+	assert.EqualError(t, err, "max depth exceeded at 1:4")
+}
@@ -4,6 +4,9 @@ package calm
 // true if one of them return true.
 func Or(ms ...MatcherFunc) MatcherFunc {
 	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
 		for _, m := range ms {
 			if m(c) {
 				return true
@@ -15,9 +18,17 @@ func Or(ms ...MatcherFunc) MatcherFunc {
 
 // And tests each matcher and returns
 // true if all of them return true.
+// Errors point at least where the
+// matcher that fails starts, even
+// if it does not tell what it
+// expected.
 func And(ms ...MatcherFunc) MatcherFunc {
 	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
 		for _, m := range ms {
+			c.reach()
 			if !m(c) {
 				return false
 			}
@@ -33,22 +44,37 @@ func AND(ms ...MatcherFunc) MatcherFunc {
 
 // Not negates the current matcher. True
 // becomes false and false becomes true.
+// Failures inside Not are not reported as
+// errors, since they make Not succeed.
 func (m MatcherFunc) Not() MatcherFunc {
 	return func(c *Code) bool {
-		return !m(c)
+		if !c.step() {
+			return false
+		}
+		c.quiet++
+		ok := m(c)
+		c.quiet--
+		return !ok && c.err == nil
 	}
 }
 
 // True forces the current matcher to return true.
 func (m MatcherFunc) True() MatcherFunc {
 	return func(c *Code) bool {
-		return m(c) || true
+		if !c.step() {
+			return false
+		}
+		m(c)
+		return c.err == nil
 	}
 }
 
 // False forces the current matcher to return false.
 func (m MatcherFunc) False() MatcherFunc {
 	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
 		return m(c) && false
 	}
 }
@@ -56,13 +82,14 @@ func (m MatcherFunc) False() MatcherFunc {
 // True forces the current matcher to return true.
 func True() MatcherFunc {
 	return func(c *Code) bool {
-		return true
+		return c.step()
 	}
 }
 
 // False forces the current matcher to return false.
 func False() MatcherFunc {
 	return func(c *Code) bool {
+		c.step()
 		return false
 	}
 }
@@ -70,6 +97,9 @@ func False() MatcherFunc {
 // If runs 'then' if 'cond' is true or 'elze' if 'cond' is false.
 func If(cond MatcherFunc, then MatcherFunc, elze MatcherFunc) MatcherFunc {
 	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
 		if cond(c) {
 			return then(c)
 		}
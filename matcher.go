@@ -1,12 +1,26 @@
 package calm
 
-import "regexp"
+import (
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
 
 // S tests if the current token matches a
 // string and moves the position if true.
 func S(s string) MatcherFunc {
+	exp := "S(" + strconv.Quote(s) + ")"
 	return func(c *Code) bool {
-		return c.Match(s)
+		if !c.step() {
+			return false
+		}
+		if c.Match(s) {
+			return true
+		}
+		c.expect(exp)
+		return false
 	}
 }
 
@@ -14,60 +28,159 @@ func S(s string) MatcherFunc {
 // reference and moves the position if true.
 func SR(s *string) MatcherFunc {
 	return func(c *Code) bool {
-		return c.Match(*s)
+		if !c.step() {
+			return false
+		}
+		if c.Match(*s) {
+			return true
+		}
+		c.expect("S(" + strconv.Quote(*s) + ")")
+		return false
 	}
 }
 
 // SOr tests if the current token matches any
 // character of the string s and moves the
-// position if true.
+// position if true. In a token code the
+// token must be that one character.
 func SOr(s string) MatcherFunc {
+	exp := "SOr(" + strconv.Quote(s) + ")"
 	return func(c *Code) bool {
-		cur := c.Curr()
+		if !c.step() {
+			return false
+		}
+		cur, ok := c.char()
 		for _, r := range s {
-			if cur == r {
+			if ok && cur == r {
 				c.Next()
 				return true
 			}
 		}
+		c.expect(exp)
 		return false
 	}
 }
 
 // F tests the current character against a rune
-// function and moves the position if true.
+// function and moves the position if true. In a
+// token code the token must be one character.
 func F(fn func(rune) bool) MatcherFunc {
-	return MatcherFunc(func(c *Code) bool {
-		if fn(c.Curr()) {
+	exp := "F(" + funcName(fn) + ")"
+	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
+		if r, ok := c.char(); ok && fn(r) {
 			c.Next()
 			return true
 		}
+		c.expect(exp)
 		return false
-	}).More()
+	}
 }
 
 // R tests if the current token matches a regular
-// expression and moves the position if true.
+// expression and moves the position if true. The
+// expression only matches at the current position.
 func R(regex string) MatcherFunc {
-	r := regexp.MustCompile(regex)
+	r := anchored(regex)
+	exp := "R(" + strconv.Quote(regex) + ")"
 	return func(c *Code) bool {
-		return c.Match(r.FindString(c.Tail()))
+		if !c.step() {
+			return false
+		}
+		if loc := r.find(c); loc != nil && loc[1] > 0 {
+			c.advance(c.text(c.pos, c.pos+loc[1]))
+			return true
+		}
+		c.expect(exp)
+		return false
 	}
 }
 
+// RGroups is like R, but it also calls f with
+// the capture groups of the regular expression.
+// Groups that do not participate in the match
+// are given as empty tokens.
+func RGroups(regex string, f func([]Token)) MatcherFunc {
+	r := anchored(regex)
+	exp := "RGroups(" + strconv.Quote(regex) + ")"
+	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
+		loc := r.find(c)
+		if loc == nil || loc[1] == 0 {
+			c.expect(exp)
+			return false
+		}
+		ini, tail := c.Mark(), c.text(c.pos, c.pos+loc[1])
+		groups := make([]Token, len(loc)/2-1)
+		for i := range groups {
+			if b, e := loc[2*i+2], loc[2*i+3]; b >= 0 {
+				m := c.after(ini, tail[:b])
+				groups[i] = Token{Text: tail[b:e], Pos: m.pos, Row: m.row, Col: m.col}
+			}
+		}
+		c.advance(tail[:loc[1]])
+		c.emit(func() { f(groups) })
+		return true
+	}
+}
+
+// anchored compiles a regular expression
+// that only matches at the start.
+func anchored(regex string) anchor {
+	prefix, _ := regexp.MustCompile(regex).LiteralPrefix()
+	return anchor{re: regexp.MustCompile(`^(?:` + regex + `)`), prefix: prefix}
+}
+
+type anchor struct {
+	re     *regexp.Regexp
+	prefix string // Literal prefix of all matches.
+}
+
+// find returns the submatch indexes
+// at the current position or nil.
+func (r anchor) find(c *Code) []int {
+	if r.prefix != "" && !c.Equal(r.prefix) {
+		return nil
+	}
+	if c.win != nil {
+		return r.re.FindReaderSubmatchIndex(&runeReader{c: c, pos: c.pos})
+	}
+	return r.re.FindStringSubmatchIndex(c.Tail())
+}
+
 // Eq tests if the current token equals a
 // string, but does not move the position.
 func Eq(s string) MatcherFunc {
+	exp := "Eq(" + strconv.Quote(s) + ")"
 	return func(c *Code) bool {
-		return c.Equal(s)
+		if !c.step() {
+			return false
+		}
+		if c.Equal(s) {
+			return true
+		}
+		c.expect(exp)
+		return false
 	}
 }
 
 // EqF tests the current character against a rune
 // function, but does not move the position.
 func EqF(fn func(rune) bool) MatcherFunc {
+	exp := "EqF(" + funcName(fn) + ")"
 	return func(c *Code) bool {
-		return fn(c.Curr())
+		if !c.step() {
+			return false
+		}
+		if r, ok := c.char(); ok && fn(r) {
+			return true
+		}
+		c.expect(exp)
+		return false
 	}
 }
 
@@ -75,6 +188,9 @@ func EqF(fn func(rune) bool) MatcherFunc {
 // there are more characters to match.
 func (m MatcherFunc) More() MatcherFunc {
 	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
 		if c.More() {
 			return m(c)
 		}
@@ -82,9 +198,17 @@ func (m MatcherFunc) More() MatcherFunc {
 	}
 }
 
-// Run implements the Matcher interface.
+// Run implements the Matcher interface. It
+// returns false when the scan was stopped.
 func (m MatcherFunc) Run(c *Code) bool {
-	return m(c)
+	return m(c) && c.err == nil
 }
 
 type MatcherFunc func(*Code) bool
+
+// funcName returns the short name of a function,
+// for example "unicode.IsDigit".
+func funcName(fn interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
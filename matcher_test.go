@@ -1,6 +1,7 @@
 package calm
 
 import (
+	"strings"
 	"testing"
 	"unicode"
 
@@ -90,3 +91,67 @@ func TestEq_EqF(t *testing.T) {
 		assert.Equal(t, tc.ok, ok, tc.in)
 	}
 }
+
+func TestR(t *testing.T) {
+
+	tt := []struct {
+		in string
+		ok bool
+		mf MatcherFunc
+		ex string
+	}{
+		{"abc", true, R("a+"), "a"},
+		{"aac", true, R("a+"), "aa"},
+		{"bac", false, R("a+"), ""},
+		{"bac", false, R("a*"), ""},
+		{"ax a", false, R("a$"), ""},
+		{"ab", true, R("b|ab"), "ab"},
+		{"abc", true, R("abc|abd"), "abc"},
+		{"abd", true, R("abc|abd"), "abd"},
+		{"ab", false, R("abc|abd"), ""},
+		{"a\nb", true, R(`a\sb`), "a\nb"},
+	}
+
+	for _, tc := range tt {
+
+		c := New(tc.in)
+
+		var tk string
+		ok := tc.mf.On(Grab(&tk)).Run(c)
+
+		assert.Equal(t, tc.ok, ok, tc.in)
+		assert.Equal(t, tc.ex, tk, tc.in)
+	}
+}
+
+func TestR_Reader(t *testing.T) {
+
+	src := strings.Repeat("x", 5000) + "\nabc123"
+
+	c := NewReader(strings.NewReader(src))
+
+	var tk Token
+	ok := And(S("x").OneToMany(), S("\n"), R(`[a-z]+\d+`).On(Emit(&tk))).Run(c)
+
+	assert.True(t, ok)
+	assert.Equal(t, Token{Text: "abc123", Pos: 5001, Row: 2, Col: 1}, tk)
+}
+
+func TestRGroups(t *testing.T) {
+
+	c := New("x\nkey = value;")
+
+	var gs []Token
+	ok := And(S("x\n"), RGroups(`(\w+) = (\w+)(!)?`, func(g []Token) { gs = g })).Run(c)
+
+	assert.True(t, ok)
+	assert.Equal(t, []Token{
+		{Text: "key", Pos: 2, Row: 2, Col: 1},
+		{Text: "value", Pos: 8, Row: 2, Col: 7},
+		{},
+	}, gs)
+	assert.Equal(t, ";", c.Tail())
+
+	assert.False(t, RGroups(`(\d)`, func([]Token) {}).Run(New("a")))
+	assert.EqualError(t, RGroups(`(\d)`, func([]Token) {}).RunErr(New("a")), `1:1: unexpected "a", expected RGroups("(\\d)")`)
+}
@@ -0,0 +1,80 @@
+package calm
+
+// Memo caches the result of the current matcher by
+// position, so it runs at most once per position
+// (packrat parsing). The AST nodes it builds are
+// cached too and replayed on later calls. On
+// callbacks are replayed only in transactional mode.
+func (m MatcherFunc) Memo() MatcherFunc {
+	id := new(int)
+	return func(c *Code) bool {
+		if c.err != nil {
+			return false
+		}
+		key := memoKey{rule: id, pos: c.pos, ind: c.ind, quiet: c.quiet > 0}
+		if e, ok := c.memo[key]; ok {
+			e.replay(c)
+			c.merge(e.fail)
+			return e.ok
+		}
+		parent, out, n := c.ast, c.fail, c.begin()
+		c.ast, c.fail = &AST{Type: "Memo"}, c.failure()
+		ok := m(c)
+		e := &memoEntry{ok: ok, end: c.Mark(), nodes: cloneAll(c.ast.Args), fail: c.fail, events: copyEvents(c.events[n:])}
+		parent.Args = append(parent.Args, c.ast.Args...)
+		c.ast, c.fail = parent, out
+		c.commit(n, true)
+		c.merge(e.fail)
+		if c.memo == nil {
+			c.memo = make(map[memoKey]*memoEntry)
+		}
+		c.memo[key] = e
+		return ok
+	}
+}
+
+type memoKey struct {
+	rule  *int
+	pos   int
+	ind   *indent
+	quiet bool // Failures are not recorded, as inside Not.
+}
+
+type memoEntry struct {
+	ok     bool
+	end    Mark
+	nodes  []*AST
+	fail   failure
+	events []func()
+}
+
+// replay replays the result of a matcher.
+func (e *memoEntry) replay(c *Code) {
+	c.Back(e.end)
+	c.ast.Args = append(c.ast.Args, cloneAll(e.nodes)...)
+	for _, ev := range e.events {
+		c.emit(ev)
+	}
+}
+
+func copyEvents(es []func()) []func() {
+	return append([]func(){}, es...)
+}
+
+// clone returns a deep copy of the AST.
+func (a *AST) clone() *AST {
+	b := *a
+	b.Args = cloneAll(a.Args)
+	return &b
+}
+
+func cloneAll(as []*AST) []*AST {
+	if as == nil {
+		return nil
+	}
+	bs := make([]*AST, len(as))
+	for i, a := range as {
+		bs[i] = a.clone()
+	}
+	return bs
+}
@@ -0,0 +1,104 @@
+package calm
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemo(t *testing.T) {
+
+	calls := 0
+	count := func(c *Code) bool {
+		calls++
+		return true
+	}
+
+	c := New("ab")
+
+	a := And(MatcherFunc(count), S("a").Leaf("A")).Memo()
+	ok := Or(And(a, S("x")).Undo(), And(a, S("b").Leaf("B"))).Run(c)
+
+	assert.True(t, ok)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "Root [ A a, B b ]", c.ast.Print("short-inline"))
+}
+
+func TestMemo_Fail(t *testing.T) {
+
+	calls := 0
+	count := func(c *Code) bool {
+		calls++
+		return true
+	}
+
+	c := New("ab")
+
+	a := And(MatcherFunc(count), S("x")).Memo()
+	ok := Or(a, a, S("a")).Run(c)
+
+	assert.True(t, ok)
+	assert.Equal(t, 1, calls)
+	assert.EqualError(t, Or(a, a).RunErr(c), `1:2: unexpected "b", expected S("x")`)
+}
+
+func TestMemo_Not(t *testing.T) {
+
+	b := S("b").Memo()
+
+	err := And(b.Not(), b).RunErr(New("a"))
+
+	assert.EqualError(t, err, `1:1: unexpected "a", expected S("b")`)
+}
+
+func TestMemo_Expression(t *testing.T) {
+
+	tt := []struct {
+		inp string
+		exp string
+	}{
+		{"2+3", "Root [ BinExpr + [ Value 2, Value 3 ] ]"},
+		{"2+3*4", "Root [ BinExpr + [ Value 2, BinExpr * [ Value 3, Value 4 ] ] ]"},
+		{"2*3+4", "Root [ BinExpr + [ BinExpr * [ Value 2, Value 3 ], Value 4 ] ]"},
+		{"2*(3+4)*5", "Root [ BinExpr * [ Value 2, BinExpr * [ BinExpr + [ Value 3, Value 4 ], Value 5 ] ] ]"},
+		{strings.Repeat("(", 30) + "1" + strings.Repeat(")", 30), "Root [ Value 1 ]"},
+	}
+
+	for _, tc := range tt {
+
+		calls := 0
+		count := func(c *Code) bool {
+			calls++
+			return true
+		}
+
+		term, setTerm := Recursive()
+		expr, setExpr := Recursive()
+
+		value := F(unicode.IsNumber).Leaf("Value")
+		factor := And(MatcherFunc(count), Or(And(S("("), expr, S(")")), value)).Memo()
+		setTerm(Or(Root(factor, S("*").Leaf("BinExpr"), term), factor).Memo())
+		setExpr(Or(Root(term, S("+").Leaf("BinExpr"), expr), term).Memo())
+
+		var ast AST
+		ok := expr.Tree(&ast).Run(New(tc.inp))
+
+		assert.True(t, ok, tc.inp)
+		assert.Equal(t, tc.exp, ast.Print("short-inline"), tc.inp)
+		assert.LessOrEqual(t, calls, len(tc.inp), tc.inp)
+	}
+}
+
+func TestMemo_Transactional(t *testing.T) {
+
+	c := New("ab").Transactional()
+
+	var s []string
+	a := S("a").On(Grabs(&s)).Memo()
+	ok := Or(AND(a, S("x")), AND(a, S("b"))).Run(c)
+
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a"}, s)
+}
@@ -25,10 +25,13 @@ func (m MatcherFunc) Next() MatcherFunc {
 // if it returns false.
 func (m MatcherFunc) Undo() MatcherFunc {
 	return MatcherFunc(func(c *Code) bool {
-		if ini := c.Mark(); !m(c) {
+		ini, n := c.hold(), c.begin()
+		ok := m(c)
+		c.release()
+		if !ok {
 			c.Back(ini)
-			return false
 		}
-		return true
+		c.commit(n, ok)
+		return ok
 	}).undoAST()
 }
@@ -0,0 +1,170 @@
+package calm
+
+// Operators creates an operator table that parses
+// expressions by precedence climbing (Pratt parsing).
+// The atom matches the operands. Each operator has
+// a binding power; higher binds tighter. Operators
+// usually build a Leaf node, for example
+// S("+").Leaf("BinExpr"), that becomes the parent
+// of its operands, like in Root.
+func Operators(atom MatcherFunc) *OpTable {
+	return &OpTable{atom: atom}
+}
+
+// OpTable is an operator table. See Operators.
+type OpTable struct {
+	atom    MatcherFunc
+	prefix  []op
+	infix   []op
+	postfix []op
+}
+
+type op struct {
+	m     MatcherFunc
+	bp    int
+	assoc assoc
+}
+
+type assoc int
+
+const (
+	left assoc = iota
+	right
+	none
+)
+
+// Prefix adds a prefix operator, like -x.
+func (t *OpTable) Prefix(m MatcherFunc, bp int) *OpTable {
+	t.prefix = append(t.prefix, op{m: m, bp: bp})
+	return t
+}
+
+// Postfix adds a postfix operator, like x!.
+func (t *OpTable) Postfix(m MatcherFunc, bp int) *OpTable {
+	t.postfix = append(t.postfix, op{m: m, bp: bp})
+	return t
+}
+
+// InfixL adds a left associative infix
+// operator. 1-2-3 is parsed as (1-2)-3.
+func (t *OpTable) InfixL(m MatcherFunc, bp int) *OpTable {
+	t.infix = append(t.infix, op{m: m, bp: bp, assoc: left})
+	return t
+}
+
+// InfixR adds a right associative infix
+// operator. 1^2^3 is parsed as 1^(2^3).
+func (t *OpTable) InfixR(m MatcherFunc, bp int) *OpTable {
+	t.infix = append(t.infix, op{m: m, bp: bp, assoc: right})
+	return t
+}
+
+// InfixN adds a non associative infix operator.
+// 1<2<3 is not parsed beyond 1<2.
+func (t *OpTable) InfixN(m MatcherFunc, bp int) *OpTable {
+	t.infix = append(t.infix, op{m: m, bp: bp, assoc: none})
+	return t
+}
+
+// Matcher returns a matcher for the expressions.
+func (t *OpTable) Matcher() MatcherFunc {
+	return MatcherFunc(func(c *Code) bool {
+		return t.expr(c, 0)
+	}).Undo()
+}
+
+// expr parses an expression whose operators
+// bind at least as tight as min.
+func (t *OpTable) expr(c *Code, min int) bool {
+	from, start := c.Mark(), len(c.ast.Args)
+	if !t.unary(c) {
+		return false
+	}
+	nonassoc := -1
+	for t.postfixOp(c, from, start, min) || t.infixOp(c, from, start, min, &nonassoc) {
+	}
+	return true
+}
+
+// unary parses a prefix operation or an atom.
+func (t *OpTable) unary(c *Code) bool {
+	for _, o := range t.prefix {
+		ini, start, n := c.hold(), len(c.ast.Args), c.begin()
+		ok := o.m(c)
+		opnd := len(c.ast.Args)
+		ok = ok && t.expr(c, o.bp)
+		c.release()
+		c.commit(n, ok)
+		if ok {
+			fold(c, ini, start, start, opnd)
+			return true
+		}
+		c.Back(ini)
+		c.ast.Args = c.ast.Args[:start]
+	}
+	return t.atom(c)
+}
+
+func (t *OpTable) postfixOp(c *Code, from Mark, start, min int) bool {
+	for _, o := range t.postfix {
+		if o.bp < min {
+			continue
+		}
+		ini, opr, n := c.hold(), len(c.ast.Args), c.begin()
+		ok := o.m(c)
+		c.release()
+		c.commit(n, ok)
+		if ok {
+			fold(c, from, start, opr, len(c.ast.Args))
+			return true
+		}
+		c.Back(ini)
+		c.ast.Args = c.ast.Args[:opr]
+	}
+	return false
+}
+
+func (t *OpTable) infixOp(c *Code, from Mark, start, min int, nonassoc *int) bool {
+	for _, o := range t.infix {
+		if o.bp < min || o.bp == *nonassoc {
+			continue
+		}
+		bp := o.bp + 1
+		if o.assoc == right {
+			bp = o.bp
+		}
+		ini, opr, n := c.hold(), len(c.ast.Args), c.begin()
+		ok := o.m(c)
+		rhs := len(c.ast.Args)
+		ok = ok && t.expr(c, bp)
+		c.release()
+		c.commit(n, ok)
+		if ok {
+			fold(c, from, start, opr, rhs)
+			if o.assoc == none {
+				*nonassoc = o.bp
+			}
+			return true
+		}
+		c.Back(ini)
+		c.ast.Args = c.ast.Args[:opr]
+	}
+	return false
+}
+
+// fold makes the nodes built by an operator, from
+// index opr to end, the parent of the other nodes
+// from index start on. Its span covers the input
+// from the mark from on. Nothing is done when the
+// operator did not build a single node.
+func fold(c *Code, from Mark, start, opr, end int) {
+	args := c.ast.Args
+	if end-opr != 1 {
+		return
+	}
+	o := args[opr]
+	o.Args = append(o.Args, args[start:opr]...)
+	o.Args = append(o.Args, args[end:]...)
+	o.Span = c.span(from, c.Mark())
+	c.ast.Args = append(args[:start], o)
+}
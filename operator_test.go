@@ -0,0 +1,70 @@
+package calm
+
+import (
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperators(t *testing.T) {
+
+	tt := []struct {
+		in  string
+		exp string
+	}{
+		{"1", "Root [ Val 1 ]"},
+		{"1+2", "Root [ Bin + [ Val 1, Val 2 ] ]"},
+		{"1-2-3", "Root [ Bin - [ Bin - [ Val 1, Val 2 ], Val 3 ] ]"},
+		{"1/2*3", "Root [ Bin * [ Bin / [ Val 1, Val 2 ], Val 3 ] ]"},
+		{"1+2*3", "Root [ Bin + [ Val 1, Bin * [ Val 2, Val 3 ] ] ]"},
+		{"1*2+3", "Root [ Bin + [ Bin * [ Val 1, Val 2 ], Val 3 ] ]"},
+		{"1^2^3", "Root [ Bin ^ [ Val 1, Bin ^ [ Val 2, Val 3 ] ] ]"},
+		{"-1", "Root [ Neg - [ Val 1 ] ]"},
+		{"--1", "Root [ Neg - [ Neg - [ Val 1 ] ] ]"},
+		{"-1^2", "Root [ Neg - [ Bin ^ [ Val 1, Val 2 ] ] ]"},
+		{"-1*2", "Root [ Bin * [ Neg - [ Val 1 ], Val 2 ] ]"},
+		{"1!", "Root [ Fact ! [ Val 1 ] ]"},
+		{"-1!", "Root [ Neg - [ Fact ! [ Val 1 ] ] ]"},
+		{"2*3!", "Root [ Bin * [ Val 2, Fact ! [ Val 3 ] ] ]"},
+		{"(1+2)*3", "Root [ Bin * [ Bin + [ Val 1, Val 2 ], Val 3 ] ]"},
+		{"1<2", "Root [ Cmp < [ Val 1, Val 2 ] ]"},
+		{"1<2<3", "Root [ Cmp < [ Val 1, Val 2 ] ]"},
+		{"1+2<3", "Root [ Cmp < [ Bin + [ Val 1, Val 2 ], Val 3 ] ]"},
+		{"1+", "Root [ Val 1 ]"},
+		{"+", "Root"},
+	}
+
+	for _, tc := range tt {
+
+		c := New(tc.in)
+
+		expr, setExpr := Recursive()
+
+		atom := Or(And(S("("), expr, S(")")), F(unicode.IsNumber).Leaf("Val"))
+		setExpr(Operators(atom).
+			InfixN(S("<").Leaf("Cmp"), 5).
+			InfixL(SOr("+-").Leaf("Bin"), 10).
+			InfixL(SOr("*/").Leaf("Bin"), 20).
+			Prefix(S("-").Leaf("Neg"), 30).
+			InfixR(S("^").Leaf("Bin"), 40).
+			Postfix(S("!").Leaf("Fact"), 50).
+			Matcher())
+
+		expr.Run(c)
+
+		assert.Equal(t, tc.exp, c.ast.Print("short-inline"), tc.in)
+	}
+}
+
+func TestOperators_NoLeaf(t *testing.T) {
+
+	c := New("1+2")
+
+	expr := Operators(F(unicode.IsNumber).Leaf("Val")).InfixL(S("+"), 10).Matcher()
+
+	ok := expr.Run(c)
+
+	assert.True(t, ok)
+	assert.Equal(t, "Root [ Val 1, Val 2 ]", c.ast.Print("short-inline"))
+}
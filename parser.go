@@ -0,0 +1,183 @@
+package calm
+
+import "strconv"
+
+// Parser is a matcher that produces a value.
+type Parser[T any] func(*Code) (T, bool)
+
+// As makes a parser that converts the token
+// of a matcher into a value with f. It fails
+// when f returns an error.
+func As[T any](m MatcherFunc, f func(string) (T, error)) Parser[T] {
+	return func(c *Code) (v T, ok bool) {
+		ok = c.try(func() bool {
+			var err error
+			ini := c.hold()
+			ok := m(c)
+			c.release()
+			if ok {
+				v, err = f(c.Token(ini, c.Mark()).Text)
+			}
+			return ok && err == nil
+		})
+		return
+	}
+}
+
+// Text makes a parser of the token of a matcher.
+func Text(m MatcherFunc) Parser[string] {
+	return As(m, func(s string) (string, error) { return s, nil })
+}
+
+// Int makes a parser that converts
+// the token of a matcher to integer.
+func Int(m MatcherFunc) Parser[int] {
+	return As(m, strconv.Atoi)
+}
+
+// Float makes a parser that converts
+// the token of a matcher to float.
+func Float(m MatcherFunc) Parser[float64] {
+	return As(m, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+}
+
+// Map converts the value of a parser with f.
+func Map[T, U any](p Parser[T], f func(T) U) Parser[U] {
+	return func(c *Code) (u U, ok bool) {
+		if v, ok := p(c); ok {
+			return f(v), true
+		}
+		return u, false
+	}
+}
+
+// Seq2 runs two parsers in sequence and
+// combines their values with f.
+func Seq2[A, B, R any](a Parser[A], b Parser[B], f func(A, B) R) Parser[R] {
+	return func(c *Code) (r R, ok bool) {
+		var x A
+		var y B
+		if c.try(func() bool {
+			if x, ok = a(c); ok {
+				y, ok = b(c)
+			}
+			return ok
+		}) {
+			r = f(x, y)
+		}
+		return r, ok
+	}
+}
+
+// Seq3 runs three parsers in sequence and
+// combines their values with f.
+func Seq3[A, B, C, R any](a Parser[A], b Parser[B], d Parser[C], f func(A, B, C) R) Parser[R] {
+	type ab struct {
+		a A
+		b B
+	}
+	return Seq2(Seq2(a, b, func(x A, y B) ab { return ab{x, y} }), d, func(x ab, y C) R { return f(x.a, x.b, y) })
+}
+
+// Between runs a parser between two
+// matchers and returns its value.
+func Between[T any](open MatcherFunc, p Parser[T], close MatcherFunc) Parser[T] {
+	return func(c *Code) (v T, ok bool) {
+		ok = c.try(func() bool {
+			if ok = open(c); ok {
+				v, ok = p(c)
+			}
+			return ok && close(c)
+		})
+		return
+	}
+}
+
+// Many runs a parser zero or many
+// times and returns its values. A
+// match that does not move the
+// position stops the scan with
+// ErrInfiniteLoop; see Min.
+func Many[T any](p Parser[T]) Parser[[]T] {
+	return func(c *Code) ([]T, bool) {
+		var vs []T
+		for {
+			ini := c.Mark()
+			v, ok := p(c)
+			if !ok {
+				return vs, c.err == nil
+			}
+			if c.stuck(ini) {
+				return vs, false
+			}
+			vs = append(vs, v)
+		}
+	}
+}
+
+// Opt runs an optional parser. Its
+// value is def when it does not match.
+func Opt[T any](p Parser[T], def T) Parser[T] {
+	return func(c *Code) (T, bool) {
+		if v, ok := p(c); ok {
+			return v, true
+		}
+		return def, c.err == nil
+	}
+}
+
+// Choice returns the value of the
+// first parser that matches.
+func Choice[T any](ps ...Parser[T]) Parser[T] {
+	return func(c *Code) (v T, ok bool) {
+		for _, p := range ps {
+			if v, ok = p(c); ok {
+				return v, true
+			}
+		}
+		return v, false
+	}
+}
+
+// Lazy allows recursive call of a parser. See Recursive.
+func Lazy[T any]() (ref Parser[T], set func(Parser[T]) Parser[T]) {
+	var p Parser[T]
+	ref = func(c *Code) (T, bool) {
+		defer c.leave()
+		if !c.enter() {
+			var zero T
+			return zero, false
+		}
+		return p(c)
+	}
+	set = func(pf Parser[T]) Parser[T] {
+		p = pf
+		return ref
+	}
+	return
+}
+
+// To makes a matcher that stores
+// the value of the parser in v.
+func (p Parser[T]) To(v *T) MatcherFunc {
+	return func(c *Code) bool {
+		x, ok := p(c)
+		if ok {
+			*v = x
+		}
+		return ok
+	}
+}
+
+// Parse runs the parser and returns its value.
+// It returns an error like RunErr when it fails.
+func (p Parser[T]) Parse(c *Code) (T, error) {
+	var v T
+	err := p.To(&v).RunErr(c)
+	return v, err
+}
+
+// try runs f like Undo runs a matcher.
+func (c *Code) try(f func() bool) bool {
+	return MatcherFunc(func(*Code) bool { return f() }).Undo()(c)
+}
@@ -0,0 +1,113 @@
+package calm
+
+import (
+	"errors"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParser_Calculator(t *testing.T) {
+
+	tt := []struct {
+		in  string
+		out float64
+		err string
+	}{
+		{"2", 2, ""},
+		{"1 + 2", 3, ""},
+		{"1 - 2 - 3", -4, ""},
+		{"2 * 3 + 4", 10, ""},
+		{"2 * (3 + 4) / 7", 2, ""},
+		{"-1.5 * 2", -3, ""},
+		{"2 * (3 + 4", 0, `1:11: unexpected end of input, expected F(unicode.IsDigit) or S(".") or SOr("Ee") or F(unicode.IsSpace) or SOr("*/") or SOr("+-") or S(")")`},
+	}
+
+	type op struct {
+		op string
+		v  float64
+	}
+	apply := func(v float64, ops []op) float64 {
+		for _, o := range ops {
+			switch o.op {
+			case "+":
+				v += o.v
+			case "-":
+				v -= o.v
+			case "*":
+				v *= o.v
+			case "/":
+				v /= o.v
+			}
+		}
+		return v
+	}
+	pair := func(o string, v float64) op { return op{o, v} }
+
+	wz := F(unicode.IsSpace).ZeroToMany()
+	expr, setExpr := Lazy[float64]()
+	factor := Between(wz, Choice(Float(Number()), Between(S("("), expr, S(")"))), wz)
+	term := Seq2(factor, Many(Seq2(Text(SOr("*/")), factor, pair)), apply)
+	setExpr(Seq2(term, Many(Seq2(Text(SOr("+-")), term, pair)), apply))
+
+	for _, tc := range tt {
+
+		c := New(tc.in)
+
+		v, err := Between(True(), expr, Next().Not()).Parse(c)
+
+		assert.Equal(t, tc.out, v, tc.in)
+		if tc.err == "" {
+			assert.NoError(t, err, tc.in)
+		} else {
+			assert.EqualError(t, err, tc.err, tc.in)
+		}
+	}
+}
+
+func TestParser(t *testing.T) {
+
+	digit := F(unicode.IsDigit)
+	fail := func(string) (int, error) { return 0, errors.New("fail") }
+
+	tt := []struct {
+		in   string
+		p    Parser[[]int]
+		out  []int
+		ok   bool
+		tail string
+	}{
+		{"12", Many(Int(digit)), []int{1, 2}, true, ""},
+		{"x", Many(Int(digit)), nil, true, "x"},
+		{"1", Map(Int(digit), func(v int) []int { return []int{v, v} }), []int{1, 1}, true, ""},
+		{"1x", Map(Opt(Int(digit), 9), func(v int) []int { return []int{v} }), []int{1}, true, "x"},
+		{"x", Map(Opt(Int(digit), 9), func(v int) []int { return []int{v} }), []int{9}, true, "x"},
+		{"12", Seq3(Int(digit), Int(digit), Opt(Int(digit), 0), func(a, b, c int) []int { return []int{a, b, c} }), []int{1, 2, 0}, true, ""},
+		{"1x", Seq2(Int(digit), Int(digit), func(a, b int) []int { return []int{a, b} }), nil, false, "1x"},
+		{"1", Map(As(digit, fail), func(v int) []int { return []int{v} }), nil, false, "1"},
+		{"1", Map(Choice(As(digit, fail), Int(digit)), func(v int) []int { return []int{v} }), []int{1}, true, ""},
+	}
+
+	for _, tc := range tt {
+
+		c := New(tc.in)
+
+		v, ok := tc.p(c)
+
+		assert.Equal(t, tc.ok, ok, tc.in)
+		assert.Equal(t, tc.out, v, tc.in)
+		assert.Equal(t, tc.tail, c.Tail(), tc.in)
+	}
+}
+
+func TestParser_To(t *testing.T) {
+
+	c := New("a1")
+
+	var v int
+	ok := And(S("a"), Int(F(unicode.IsDigit)).To(&v)).Run(c)
+
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+}
@@ -0,0 +1,264 @@
+package calm
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Profile collects statistics of named rules (see
+// Named). Use it with Code.Profile. A profile can
+// collect from many codes.
+type Profile struct {
+	rules   map[string]*RuleStats
+	samples map[string]*profSample
+	stack   []profFrame
+	active  map[string]int
+	start   time.Time
+}
+
+// RuleStats are the statistics of a named rule.
+type RuleStats struct {
+	Name      string
+	Calls     int           // How many times the rule ran.
+	Successes int           // How many times the rule matched.
+	Failures  int           // How many times the rule did not match.
+	Consumed  int           // Bytes consumed by the matches.
+	Rewound   int           // Bytes given back by Back inside the rule, but not inside its inner rules.
+	Time      time.Duration // Cumulative time, with the inner rules.
+}
+
+type profFrame struct {
+	name  string
+	ini   time.Time
+	inner time.Duration // Time spent in inner rules.
+}
+
+// profSample are the statistics of a stack of rules.
+type profSample struct {
+	stack   []string // Innermost rule first.
+	calls   int64
+	self    time.Duration
+	rewound int64
+}
+
+// Profile collects statistics of the named
+// rules into p while the code is scanned.
+func (c *Code) Profile(p *Profile) *Code {
+	if p.rules == nil {
+		p.rules = map[string]*RuleStats{}
+		p.samples = map[string]*profSample{}
+		p.active = map[string]int{}
+		p.start = time.Now()
+	}
+	c.prof = p
+	return c
+}
+
+func (p *Profile) enter(rule string) {
+	p.stack = append(p.stack, profFrame{name: rule, ini: time.Now()})
+	p.active[rule]++
+}
+
+func (p *Profile) exit(rule string, ok bool, consumed int) {
+	f := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+	p.active[rule]--
+	dur := time.Since(f.ini)
+	if len(p.stack) > 0 {
+		p.stack[len(p.stack)-1].inner += dur
+	}
+	r := p.rules[rule]
+	if r == nil {
+		r = &RuleStats{Name: rule}
+		p.rules[rule] = r
+	}
+	r.Calls++
+	if ok {
+		r.Successes++
+		r.Consumed += consumed
+	} else {
+		r.Failures++
+	}
+	if p.active[rule] == 0 {
+		// Recursive calls are already
+		// in the time of the outer call.
+		r.Time += dur
+	}
+	s := p.sample(f.name)
+	s.calls++
+	s.self += dur - f.inner
+}
+
+// rewind records that n bytes were
+// given back in the innermost rule.
+func (p *Profile) rewind(n int) {
+	if len(p.stack) == 0 {
+		return
+	}
+	name := p.stack[len(p.stack)-1].name
+	p.rules[name] = p.stats(name)
+	p.rules[name].Rewound += n
+	p.sample(name).rewound += int64(n)
+}
+
+func (p *Profile) stats(rule string) *RuleStats {
+	if r := p.rules[rule]; r != nil {
+		return r
+	}
+	return &RuleStats{Name: rule}
+}
+
+// sample returns the sample of the current
+// stack with rule as the innermost rule.
+func (p *Profile) sample(rule string) *profSample {
+	stack := []string{rule}
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		stack = append(stack, p.stack[i].name)
+	}
+	key := strings.Join(stack, "\x00")
+	s := p.samples[key]
+	if s == nil {
+		s = &profSample{stack: stack}
+		p.samples[key] = s
+	}
+	return s
+}
+
+// Stats returns the statistics of each rule
+// sorted by cost, the slowest rule first.
+func (p *Profile) Stats() []RuleStats {
+	out := make([]RuleStats, 0, len(p.rules))
+	for _, r := range p.rules {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Time != out[j].Time {
+			return out[i].Time > out[j].Time
+		}
+		if out[i].Calls != out[j].Calls {
+			return out[i].Calls > out[j].Calls
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Report writes the statistics of each rule
+// as a table sorted by cost.
+func (p *Profile) Report(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "calls\tok\tfail\tconsumed\trewound\ttime\t  rule")
+	for _, r := range p.Stats() {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%s\t  %s\n", r.Calls, r.Successes, r.Failures, r.Consumed, r.Rewound, r.Time, r.Name)
+	}
+	return tw.Flush()
+}
+
+// WritePprof writes the profile in the gzipped protobuf
+// format of pprof, so it can be seen with go tool pprof.
+// Each sample is a stack of rules with the values calls,
+// time (not counting inner rules) and rewound bytes.
+func (p *Profile) WritePprof(w io.Writer) error {
+	var b protoBuf
+	strs := map[string]int{"": 0}
+	table := []string{""}
+	str := func(s string) uint64 {
+		if i, ok := strs[s]; ok {
+			return uint64(i)
+		}
+		strs[s] = len(table)
+		table = append(table, s)
+		return uint64(len(table) - 1)
+	}
+	valueType := func(typ, unit string) []byte {
+		var v protoBuf
+		v.uint(1, str(typ))
+		v.uint(2, str(unit))
+		return v.b
+	}
+	b.bytes(1, valueType("calls", "count"))
+	b.bytes(1, valueType("time", "nanoseconds"))
+	b.bytes(1, valueType("rewound", "bytes"))
+	keys := make([]string, 0, len(p.samples))
+	for k := range p.samples {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	funcs := map[string]uint64{}
+	var names []string
+	for _, k := range keys {
+		s := p.samples[k]
+		locs := make([]uint64, len(s.stack))
+		for i, name := range s.stack {
+			if _, ok := funcs[name]; !ok {
+				funcs[name] = uint64(len(names) + 1)
+				names = append(names, name)
+			}
+			locs[i] = funcs[name]
+		}
+		var v protoBuf
+		v.packed(1, locs)
+		v.packed(2, []uint64{uint64(s.calls), uint64(s.self), uint64(s.rewound)})
+		b.bytes(2, v.b)
+	}
+	for i, name := range names {
+		id := uint64(i + 1)
+		var line, loc, fn protoBuf
+		line.uint(1, id)
+		loc.uint(1, id)
+		loc.bytes(4, line.b)
+		b.bytes(4, loc.b)
+		fn.uint(1, id)
+		fn.uint(2, str(name))
+		fn.uint(3, str(name))
+		b.bytes(5, fn.b)
+	}
+	for _, s := range table {
+		b.bytes(6, []byte(s))
+	}
+	b.uint(9, uint64(p.start.UnixNano()))
+	b.uint(10, uint64(time.Since(p.start)))
+	b.uint(14, str("time"))
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.b); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// protoBuf encodes protocol buffers.
+type protoBuf struct {
+	b []byte
+}
+
+func (p *protoBuf) varint(v uint64) {
+	for v >= 0x80 {
+		p.b = append(p.b, byte(v)|0x80)
+		v >>= 7
+	}
+	p.b = append(p.b, byte(v))
+}
+
+func (p *protoBuf) uint(field int, v uint64) {
+	p.varint(uint64(field) << 3)
+	p.varint(v)
+}
+
+func (p *protoBuf) bytes(field int, v []byte) {
+	p.varint(uint64(field)<<3 | 2)
+	p.varint(uint64(len(v)))
+	p.b = append(p.b, v...)
+}
+
+func (p *protoBuf) packed(field int, vs []uint64) {
+	var v protoBuf
+	for _, x := range vs {
+		v.varint(x)
+	}
+	p.bytes(field, v.b)
+}
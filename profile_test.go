@@ -0,0 +1,164 @@
+package calm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfile(t *testing.T) {
+
+	num := SOr("0123456789").OneToMany().Named("num")
+	sub := AND(num, S("-"), num).Named("sub")
+	sum := AND(num, S("+"), num).Named("sum")
+
+	var p Profile
+	ok := Or(sub, sum).Run(New("1+23").Profile(&p))
+
+	stats := map[string]RuleStats{}
+	for _, s := range p.Stats() {
+		s.Time = 0
+		stats[s.Name] = s
+	}
+
+	assert.True(t, ok)
+	assert.Equal(t, map[string]RuleStats{
+		"num": {Name: "num", Calls: 3, Successes: 3, Consumed: 4},
+		"sub": {Name: "sub", Calls: 1, Failures: 1, Rewound: 1},
+		"sum": {Name: "sum", Calls: 1, Successes: 1, Consumed: 4},
+	}, stats)
+}
+
+func TestProfile_Recursive(t *testing.T) {
+
+	var p Profile
+	ok := Json().Run(New("[[[1]]]").Profile(&p))
+
+	var arr RuleStats
+	for _, s := range p.Stats() {
+		if s.Name == "object or array" {
+			arr = s
+		}
+	}
+
+	assert.True(t, ok)
+	assert.Equal(t, 3, arr.Calls)
+	assert.Equal(t, 3, arr.Successes)
+	assert.Equal(t, 7+5+3, arr.Consumed)
+	assert.Equal(t, "object or array", p.Stats()[0].Name)
+}
+
+func TestProfile_Report(t *testing.T) {
+
+	var p Profile
+	S("a").Named("a").ZeroToMany().Run(New("aa").Profile(&p))
+
+	var b strings.Builder
+	err := p.Report(&b)
+
+	lines := strings.Split(b.String(), "\n")
+
+	assert.NoError(t, err)
+	assert.Len(t, lines, 3)
+	assert.Equal(t, "calls  ok  fail  consumed  rewound", strings.Join(strings.Fields(lines[0])[:5], "  "))
+	assert.Equal(t, []string{"3", "2", "1", "2", "0"}, strings.Fields(lines[1])[:5])
+	assert.True(t, strings.HasSuffix(lines[1], "  a"))
+}
+
+func TestProfile_WritePprof(t *testing.T) {
+
+	var p Profile
+	Json().Run(New(`[1, {"a": 2}]`).Profile(&p))
+
+	var b bytes.Buffer
+	err := p.WritePprof(&b)
+	assert.NoError(t, err)
+
+	zr, err := gzip.NewReader(&b)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+
+	// Decodes the fields of perftools.profiles.Profile.
+	prof := protoFields(t, data)
+	var strs []string
+	for _, f := range prof[6] {
+		strs = append(strs, string(f.b))
+	}
+	var types []string
+	for _, f := range prof[1] {
+		vt := protoFields(t, f.b)
+		types = append(types, strs[vt[1][0].v]+"/"+strs[vt[2][0].v])
+	}
+	funcs := map[uint64]string{}
+	for _, f := range prof[5] {
+		fn := protoFields(t, f.b)
+		funcs[fn[1][0].v] = strs[fn[2][0].v]
+	}
+	locs := map[uint64]string{}
+	for _, f := range prof[4] {
+		loc := protoFields(t, f.b)
+		line := protoFields(t, loc[4][0].b)
+		locs[loc[1][0].v] = funcs[line[1][0].v]
+	}
+	calls := map[string]int{}
+	for _, f := range prof[2] {
+		sample := protoFields(t, f.b)
+		ids, values := protoPacked(t, sample[1][0].b), protoPacked(t, sample[2][0].b)
+		assert.Len(t, values, 3)
+		calls[locs[ids[0]]] += int(values[0])
+	}
+
+	assert.Equal(t, []string{"calls/count", "time/nanoseconds", "rewound/bytes"}, types)
+	assert.Equal(t, "time", strs[prof[14][0].v])
+	assert.NotZero(t, prof[10][0].v)
+	for _, st := range p.Stats() {
+		assert.Equal(t, st.Calls, calls[st.Name], st.Name)
+	}
+	assert.Contains(t, calls, "object field")
+}
+
+type protoField struct {
+	v uint64 // Value of a varint field.
+	b []byte // Value of a bytes field.
+}
+
+// protoFields decodes a protocol buffers
+// message into its fields by number.
+func protoFields(t *testing.T, b []byte) map[int][]protoField {
+	fs := map[int][]protoField{}
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		assert.Greater(t, n, 0)
+		b = b[n:]
+		v, n := binary.Uvarint(b)
+		assert.Greater(t, n, 0)
+		b = b[n:]
+		f := protoField{v: v}
+		switch key & 7 {
+		case 0:
+		case 2:
+			f.b, b = b[:v], b[v:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fs[int(key>>3)] = append(fs[int(key>>3)], f)
+	}
+	return fs
+}
+
+// protoPacked decodes packed varints.
+func protoPacked(t *testing.T, b []byte) []uint64 {
+	var vs []uint64
+	for len(b) > 0 {
+		v, n := binary.Uvarint(b)
+		assert.Greater(t, n, 0)
+		vs, b = append(vs, v), b[n:]
+	}
+	return vs
+}
@@ -1,14 +1,73 @@
 package calm
 
 // Recursive allows recursive call of a matcher.
+// Each call through ref is a nesting level; see
+// MaxDepth. set returns ref, so the outermost
+// call is a nesting level too.
 func Recursive() (ref MatcherFunc, set func(MatcherFunc) MatcherFunc) {
 	var m MatcherFunc
 	ref = func(c *Code) bool {
-		return m(c)
+		ok := c.enter() && m(c)
+		c.leave()
+		return ok
 	}
 	set = func(mf MatcherFunc) MatcherFunc {
 		m = mf
-		return mf
+		return ref
+	}
+	return
+}
+
+// LeftRecursive is like Recursive, but it also allows
+// the matcher to call itself in its leftmost position,
+// for example expr := Or(Root(expr, S("+"), term), term).
+// It grows the match from a failing seed until it can
+// not consume more, which builds left-leaning trees.
+func LeftRecursive() (ref MatcherFunc, set func(MatcherFunc) MatcherFunc) {
+	var m MatcherFunc
+	id := new(int)
+	ref = func(c *Code) bool {
+		key := memoKey{rule: id, pos: c.pos, ind: c.ind}
+		if seed, ok := c.seeds[key]; ok {
+			// A left recursive call. Use the seed.
+			seed.replay(c)
+			return seed.ok
+		}
+		if c.seeds == nil {
+			c.seeds = make(map[memoKey]*memoEntry)
+		}
+		if !c.enter() {
+			c.leave()
+			return false
+		}
+		defer c.leave()
+		ini, n := c.hold(), c.begin()
+		seed := &memoEntry{end: ini}
+		c.seeds[key] = seed
+		for {
+			parent := c.ast
+			c.ast = &AST{Type: "Seed"}
+			ok := m(c)
+			end, nodes, events := c.Mark(), c.ast.Args, copyEvents(c.events[n:])
+			c.ast = parent
+			c.Back(ini)
+			c.events = c.events[:n]
+			if !ok || seed.ok && end.pos <= seed.end.pos {
+				break
+			}
+			*seed = memoEntry{ok: ok, end: end, nodes: nodes, events: events}
+		}
+		delete(c.seeds, key)
+		c.release()
+		c.Back(seed.end)
+		c.ast.Args = append(c.ast.Args, seed.nodes...)
+		c.events = append(c.events, seed.events...)
+		c.commit(n, seed.ok)
+		return seed.ok
+	}
+	set = func(mf MatcherFunc) MatcherFunc {
+		m = mf
+		return ref
 	}
 	return
 }
@@ -38,3 +38,54 @@ func TestRecursive(t *testing.T) {
 		assert.Equal(t, tc.ok, ok, tc.in)
 	}
 }
+
+func TestLeftRecursive(t *testing.T) {
+
+	tt := []struct {
+		in  string
+		ok  bool
+		exp string
+	}{
+		{"1", true, "Root [ Val 1 ]"},
+		{"1-2", true, "Root [ Op - [ Val 1, Val 2 ] ]"},
+		{"1-2-3", true, "Root [ Op - [ Op - [ Val 1, Val 2 ], Val 3 ] ]"},
+		{"1-2*3/4", true, "Root [ Op - [ Val 1, Op / [ Op * [ Val 2, Val 3 ], Val 4 ] ] ]"},
+		{"1*2-3*4-5", true, "Root [ Op - [ Op - [ Op * [ Val 1, Val 2 ], Op * [ Val 3, Val 4 ] ], Val 5 ] ]"},
+		{"(1-2)-(3-4)", true, "Root [ Op - [ Op - [ Val 1, Val 2 ], Op - [ Val 3, Val 4 ] ] ]"},
+		{"1-", true, "Root [ Val 1 ]"},
+		{"-", false, "Root"},
+	}
+
+	for _, tc := range tt {
+
+		c := New(tc.in)
+
+		expr, setExpr := LeftRecursive()
+		term, setTerm := LeftRecursive()
+
+		value := F(unicode.IsNumber).Leaf("Val")
+		factor := Or(And(S("("), expr, S(")")), value)
+		setTerm(Or(Root(term, SOr("*/").Leaf("Op"), factor), factor))
+		setExpr(Or(Root(expr, SOr("+-").Leaf("Op"), term), term))
+
+		ok := expr.Run(c)
+
+		assert.Equal(t, tc.ok, ok, tc.in)
+		assert.Equal(t, tc.exp, c.ast.Print("short-inline"), tc.in)
+	}
+}
+
+func TestLeftRecursive_Transactional(t *testing.T) {
+
+	c := New("1-2-3").Transactional()
+
+	var s []string
+	expr, setExpr := LeftRecursive()
+	value := F(unicode.IsNumber).On(Grabs(&s))
+	setExpr(Or(And(expr, S("-"), value), value))
+
+	ok := expr.Run(c)
+
+	assert.True(t, ok)
+	assert.Equal(t, []string{"1", "2", "3"}, s)
+}
@@ -19,13 +19,26 @@ func (m MatcherFunc) ZeroToOne() MatcherFunc {
 }
 
 // Min matches a minimum number of tokens.
+// A match that does not move the position
+// would repeat forever, so it stops the
+// scan with ErrInfiniteLoop.
 func (t MatcherFunc) Min(n int) MatcherFunc {
 	return func(c *Code) bool {
+		if !c.step() {
+			return false
+		}
 		i := 0
-		for t(c) {
+		for {
+			ini := c.Mark()
+			if !t(c) {
+				break
+			}
+			if c.stuck(ini) {
+				return false
+			}
 			i++
 		}
-		return i >= n
+		return i >= n && c.err == nil
 	}
 }
 
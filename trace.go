@@ -0,0 +1,129 @@
+package calm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Trace writes the enter and exit of every named
+// rule (see Named) to w. The format can be:
+//
+//	tree    an indented tree, one line per enter and exit.
+//	json    JSON lines, one object per enter and exit.
+//	chrome  a Chrome trace_event file, with one complete
+//	        event per rule call; open it in chrome://tracing
+//	        or https://ui.perfetto.dev.
+//
+// The chrome format is a JSON array that is left
+// open, as the scan does not know when it ends;
+// both viewers accept that.
+//
+// It panics if the format is unknown. An error
+// writing to w stops the scan with an error;
+// see Err.
+func (c *Code) Trace(w io.Writer, format string) *Code {
+	switch format {
+	case "tree", "json", "chrome":
+		c.tracer = &tracer{w: w, format: format, start: time.Now()}
+	default:
+		panic(fmt.Sprintf("unknown trace format %q", format))
+	}
+	return c
+}
+
+type tracer struct {
+	w      io.Writer
+	format string
+	depth  int
+	start  time.Time
+	times  []time.Time // Enter time of each open rule.
+	events int
+	err    error // First write error.
+}
+
+// traceEvent is an event of the json format.
+type traceEvent struct {
+	Event string    `json:"event"`
+	Rule  string    `json:"rule"`
+	Depth int       `json:"depth"`
+	Start tracePos  `json:"start"`
+	End   *tracePos `json:"end,omitempty"`
+	Ok    *bool     `json:"ok,omitempty"`
+	Text  *string   `json:"text,omitempty"`
+}
+
+type tracePos struct {
+	Pos int `json:"pos"`
+	Row int `json:"row"`
+	Col int `json:"col"`
+}
+
+func (t *tracer) enter(c *Code, rule string, ini Mark) {
+	switch t.format {
+	case "tree":
+		t.printf(c, "%s> %s %d:%d\n", strings.Repeat("  ", t.depth), rule, ini.row, ini.col)
+	case "json":
+		t.json(c, traceEvent{Event: "enter", Rule: rule, Depth: t.depth, Start: tracePos{ini.pos, ini.row, ini.col}})
+	case "chrome":
+		t.times = append(t.times, time.Now())
+	}
+	t.depth++
+}
+
+func (t *tracer) exit(c *Code, rule string, ini Mark, ok bool) {
+	t.depth--
+	end := c.Mark()
+	text := c.Token(ini, end).Text
+	switch t.format {
+	case "tree":
+		res := "fail"
+		if ok {
+			res = "ok"
+		}
+		t.printf(c, "%s< %s %s %d:%d-%d:%d %q\n", strings.Repeat("  ", t.depth), rule, res, ini.row, ini.col, end.row, end.col, text)
+	case "json":
+		t.json(c, traceEvent{Event: "exit", Rule: rule, Depth: t.depth, Start: tracePos{ini.pos, ini.row, ini.col}, End: &tracePos{end.pos, end.row, end.col}, Ok: &ok, Text: &text})
+	case "chrome":
+		beg := t.times[len(t.times)-1]
+		t.times = t.times[:len(t.times)-1]
+		sep := ",\n"
+		if t.events == 0 {
+			sep = "[\n"
+		}
+		t.events++
+		ev, _ := json.Marshal(map[string]interface{}{
+			"name": rule,
+			"ph":   "X",
+			"ts":   float64(beg.Sub(t.start).Nanoseconds()) / 1e3,
+			"dur":  float64(time.Since(beg).Nanoseconds()) / 1e3,
+			"pid":  1,
+			"tid":  1,
+			"args": map[string]interface{}{
+				"ok":    ok,
+				"start": fmt.Sprintf("%d:%d", ini.row, ini.col),
+				"end":   fmt.Sprintf("%d:%d", end.row, end.col),
+				"text":  text,
+			},
+		})
+		t.printf(c, "%s%s", sep, ev)
+	}
+}
+
+func (t *tracer) json(c *Code, ev traceEvent) {
+	b, _ := json.Marshal(ev)
+	t.printf(c, "%s\n", b)
+}
+
+// printf writes to the trace. The first write
+// error stops the trace and the scan.
+func (t *tracer) printf(c *Code, format string, args ...interface{}) {
+	if t.err != nil {
+		return
+	}
+	if _, t.err = fmt.Fprintf(t.w, format, args...); t.err != nil {
+		c.abort(fmt.Errorf("trace: %w", t.err))
+	}
+}
@@ -0,0 +1,103 @@
+package calm
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+
+	num := SOr("0123456789").OneToMany().Named("num")
+	sum := And(num, S("+").Named("plus"), num).Named("sum")
+
+	var b strings.Builder
+	c := New("1+23").Trace(&b, "tree")
+	ok := Or(AND(num, S("-"), num).Named("sub"), sum).Run(c)
+
+	exp := `> sub 1:1
+  > num 1:1
+  < num ok 1:1-1:2 "1"
+< sub fail 1:1-1:1 ""
+> sum 1:1
+  > num 1:1
+  < num ok 1:1-1:2 "1"
+  > plus 1:2
+  < plus ok 1:2-1:3 "+"
+  > num 1:3
+  < num ok 1:3-1:5 "23"
+< sum ok 1:1-1:5 "1+23"
+`
+
+	assert.True(t, ok)
+	assert.Equal(t, exp, b.String())
+}
+
+func TestTrace_Json(t *testing.T) {
+
+	var b strings.Builder
+	c := New("ab").Trace(&b, "json")
+	And(S("a").Named("a"), S("x").Named("x")).Run(c)
+
+	exp := `{"event":"enter","rule":"a","depth":0,"start":{"pos":0,"row":1,"col":1}}
+{"event":"exit","rule":"a","depth":0,"start":{"pos":0,"row":1,"col":1},"end":{"pos":1,"row":1,"col":2},"ok":true,"text":"a"}
+{"event":"enter","rule":"x","depth":0,"start":{"pos":1,"row":1,"col":2}}
+{"event":"exit","rule":"x","depth":0,"start":{"pos":1,"row":1,"col":2},"end":{"pos":1,"row":1,"col":2},"ok":false,"text":""}
+`
+
+	assert.Equal(t, exp, b.String())
+}
+
+func TestTrace_Chrome(t *testing.T) {
+
+	var b strings.Builder
+	c := New("[1]").Trace(&b, "chrome")
+	ok := Json().Run(c)
+
+	var evs []struct {
+		Name string
+		Ph   string
+		Ts   float64
+		Dur  float64
+		Args struct {
+			Ok    bool
+			Start string
+			End   string
+			Text  string
+		}
+	}
+	err := json.Unmarshal([]byte(b.String()+"]"), &evs)
+
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	last := evs[len(evs)-1]
+	assert.Equal(t, "object or array", last.Name)
+	assert.Equal(t, "X", last.Ph)
+	assert.True(t, last.Args.Ok)
+	assert.Equal(t, "1:1", last.Args.Start)
+	assert.Equal(t, "1:4", last.Args.End)
+	assert.Equal(t, "[1]", last.Args.Text)
+	for _, ev := range evs {
+		assert.True(t, ev.Ts >= last.Ts)
+		assert.True(t, ev.Ts+ev.Dur <= last.Ts+last.Dur)
+	}
+}
+
+func TestTrace_UnknownFormat(t *testing.T) {
+
+	assert.PanicsWithValue(t, `unknown trace format "x"`, func() { New("a").Trace(nil, "x") })
+}
+
+func TestTrace_WriteError(t *testing.T) {
+
+	c := New("ab").Trace(failWriter{}, "tree")
+
+	err := And(S("a").Named("a"), S("b").Named("b")).RunErr(c)
+
+	assert.EqualError(t, err, "trace: write failed at 1:1")
+	assert.True(t, errors.Is(err, errWrite))
+	assert.Equal(t, 0, c.pos)
+}
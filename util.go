@@ -11,28 +11,28 @@ func String(quote string) MatcherFunc {
 		S(quote),
 		Until(S(`\`+quote).False(), Eq(quote), Eq("\n")).True(),
 		S(quote),
-	)
+	).Named("string")
 }
 
 // Tag matches a tag.
 func Tag(open, close string) MatcherFunc {
 	tag, setTag := Recursive()
-	body := Or(Until(Eq(open), Eq(close)), tag)
-	return setTag(AND(S(open), body.ZeroToMany(), S(close)))
+	body := Or(Until(Eq(open), Eq(close)), And(Eq(open), tag).Named("tag"))
+	return setTag(AND(S(open), body.ZeroToMany(), S(close)).Named("tag"))
 }
 
 // Json matches a Json.
 func Json() MatcherFunc {
 	// BNF from https://www.json.org
 	jsn, setJsn := Recursive()
-	wz := F(unicode.IsSpace).ZeroToMany()
-	value := And(wz, Or(S("true"), S("false"), S("null"), Number(), String("\""), jsn), wz)
-	objField := And(wz, String("\""), wz, S(":"), value)
+	wz := F(unicode.IsSpace).ZeroToMany().quiet()
+	value := And(wz, Or(S("true"), S("false"), S("null"), Number(), String("\""), jsn).Named("value"), wz)
+	objField := And(wz, String("\""), wz, S(":"), value).Named("object field")
 	emptyObj := AND(S("{"), wz, S("}"))
 	emptyArr := AND(S("["), wz, S("]"))
 	obj := AND(S("{"), objField, AND(S(","), objField).ZeroToMany(), S("}"))
 	arr := AND(S("["), value, AND(S(","), value).ZeroToMany(), S("]"))
-	return setJsn(Or(emptyObj, obj, emptyArr, arr))
+	return setJsn(Or(emptyObj, obj, emptyArr, arr).Named("object or array"))
 }
 
 // Number matches a number.
@@ -42,14 +42,15 @@ func Number() MatcherFunc {
 	sign := SOr("+-").ZeroToOne()
 	exponent := If(SOr("Ee"), And(sign, digits), True())
 	fraction := If(S("."), digits, True())
-	return AND(integer, fraction, exponent)
+	return AND(integer, fraction, exponent).Named("number")
 }
 
 // Debug prints debug info to the stdout.
 func (m MatcherFunc) Debug() MatcherFunc {
 	return func(c *Code) bool {
-		ini := c.Mark()
+		ini := c.hold()
 		okz := m(c)
+		c.release()
 		end := c.Mark()
 		tkn := c.Token(ini, end)
 		fmt.Printf("[debug] Match: %-5t Token: %-3s Pos: %d Row: %d Col: %d\n", okz, "'"+tkn.Text+"'", tkn.Pos, tkn.Row, tkn.Col)